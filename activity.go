@@ -0,0 +1,77 @@
+package vkc
+
+import (
+	"context"
+	"time"
+
+	"github.com/SevereCloud/vksdk/v3/api"
+)
+
+// Тип статуса активности бота в беседе (метод messages.setActivity).
+type Activity string
+
+const (
+	ActivityTyping       Activity = "typing"
+	ActivityAudioMessage Activity = "audiomessage"
+	ActivityPhoto        Activity = "photo"
+	ActivityVideo        Activity = "video"
+	ActivityFile         Activity = "file"
+)
+
+// Интервал обновления статуса активности. VK показывает статус около 10 секунд, поэтому его нужно обновлять чаще.
+var ActivityRefreshInterval = 5 * time.Second
+
+// Однократная отправка статуса активности ("печатает…", "записывает голосовое…") в беседу, из которой пришла команда.
+func (ctx CommandContext[DEPS]) SetActivity(activity Activity) error {
+	if ctx.VK == nil {
+		return ErrNoVK
+	}
+	p := api.Params{
+		"peer_id": ctx.Message.PeerID,
+		"type":    string(activity),
+	}
+	if groupID := groupIDFromContext(ctx.Context); groupID != 0 {
+		p["group_id"] = groupID
+	}
+	if ctx.Context != nil {
+		p.WithContext(ctx.Context)
+	}
+	_, err := ctx.VK.MessagesSetActivity(p)
+	return err
+}
+
+// Отображение статуса активности до вызова возвращенной функции или отмены контекста команды.
+// Статус обновляется каждые [ActivityRefreshInterval]; ошибки обновления игнорируются.
+// Функция stop не ждет завершения текущего запроса: он прерывается отменой контекста.
+//
+// Пример использования:
+//
+//	stop := ctx.StartActivity(ActivityTyping)
+//	defer stop()
+//	result := longComputation()
+//	stop()
+//	return ctx.SendText(result)
+//
+// Для всей команды удобнее задать поле [CommandHandler.Activity].
+func (ctx CommandContext[DEPS]) StartActivity(activity Activity) (stop func()) {
+	parent := ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	activityCtx, cancel := context.WithCancel(parent)
+	ctx.Context = activityCtx
+
+	go func() {
+		ticker := time.NewTicker(ActivityRefreshInterval)
+		defer ticker.Stop()
+		for activityCtx.Err() == nil {
+			ctx.SetActivity(activity)
+			select {
+			case <-activityCtx.Done():
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return cancel
+}
@@ -0,0 +1,86 @@
+package vkc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SevereCloud/vksdk/v3/api"
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+func TestHandlerActivity(t *testing.T) {
+	interval := ActivityRefreshInterval
+	ActivityRefreshInterval = 10 * time.Millisecond
+	defer func() { ActivityRefreshInterval = interval }()
+
+	vk, calls := newRecordingVK(t)
+	commands := Commands[any]{
+		Prefix: PrefixText("!"),
+		Handlers: []*CommandHandler[any]{
+			{
+				Pattern:  Text("slow"),
+				Activity: ActivityTyping,
+				Executor: func(ctx CommandContext[any]) error {
+					time.Sleep(35 * time.Millisecond)
+					return nil
+				},
+			},
+		},
+	}
+
+	if err := commands.ProcessCommands(context.Background(), vk, newTestMessage(1, 1, "!slow")); err != nil {
+		t.Fatalf("ProcessCommands() error = %v", err)
+	}
+	n := len(calls())
+	if n < 2 {
+		t.Errorf("setActivity calls = %d, want at least 2", n)
+	}
+	for _, call := range calls() {
+		if call.method != "messages.setActivity" || call.params.Get("type") != "typing" || call.params.Get("peer_id") != "1" {
+			t.Errorf("unexpected call %s %v", call.method, call.params)
+		}
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if after := len(calls()); after != n {
+		t.Errorf("setActivity calls after executor returned = %d, want %d", after, n)
+	}
+}
+
+func TestStartActivityStopDoesNotWait(t *testing.T) {
+	started := make(chan struct{}, 1)
+	canceled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// сервер замечает разрыв соединения только после чтения тела запроса
+		r.ParseForm()
+		started <- struct{}{}
+		<-r.Context().Done()
+		close(canceled)
+	}))
+	defer server.Close()
+	vk := api.NewVK("token")
+	vk.MethodURL = server.URL + "/"
+
+	ctx := CommandContext[any]{Context: context.Background(), VK: vk, Message: object.MessagesMessage{PeerID: 1}}
+	stop := ctx.StartActivity(ActivityTyping)
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stop() waits for the in-flight setActivity call")
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("in-flight setActivity call was not canceled")
+	}
+}
@@ -0,0 +1,211 @@
+package vkc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/SevereCloud/vksdk/v3/api"
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+// Тип загружаемого вложения.
+type UploadType int
+
+const (
+	UploadPhoto UploadType = iota
+	UploadDocument
+	UploadAudioMessage
+	UploadGraffiti
+)
+
+func (t UploadType) String() string {
+	switch t {
+	case UploadPhoto:
+		return "photo"
+	case UploadDocument:
+		return "doc"
+	case UploadAudioMessage:
+		return "audio_message"
+	case UploadGraffiti:
+		return "graffiti"
+	}
+	return "unknown"
+}
+
+// Файл для загрузки в сообщения. Создается функциями [UploadFromBytes], [UploadFromReader] и [UploadFromFile].
+type Upload struct {
+	Type UploadType
+	// Имя файла. Для документов отображается как название.
+	Name string
+	Data []byte
+}
+
+// Подготовка загрузки из среза байтов.
+func UploadFromBytes(t UploadType, name string, data []byte) Upload {
+	return Upload{Type: t, Name: name, Data: data}
+}
+
+// Подготовка загрузки из [io.Reader]. Данные читаются целиком, чтобы посчитать хеш для кеша вложений.
+func UploadFromReader(t UploadType, name string, r io.Reader) (Upload, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Upload{}, fmt.Errorf("read upload: %w", err)
+	}
+	return UploadFromBytes(t, name, data), nil
+}
+
+// Подготовка загрузки из файла на диске. Имя загрузки совпадает с именем файла.
+func UploadFromFile(t UploadType, path string) (Upload, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Upload{}, fmt.Errorf("read upload: %w", err)
+	}
+	return UploadFromBytes(t, filepath.Base(path), data), nil
+}
+
+func (upload Upload) hash() string {
+	sum := sha256.Sum256(upload.Data)
+	return upload.Type.String() + ":" + hex.EncodeToString(sum[:])
+}
+
+// Загрузчик вложений для сообщений. Реализуется [*api.VK]; в тестах можно подставить свою реализацию через [Commands.Uploader].
+type Uploader interface {
+	UploadMessagesPhoto(peerID int, file io.Reader) (api.PhotosSaveMessagesPhotoResponse, error)
+	UploadMessagesDoc(peerID int, typeDoc, title, tags string, file io.Reader) (api.DocsSaveResponse, error)
+}
+
+// Кеш загруженных вложений по хешу содержимого. Позволяет не загружать один и тот же файл повторно. Нулевое значение готово к использованию.
+type AttachmentCache struct {
+	mu    sync.Mutex
+	items map[string]string
+}
+
+func (cache *AttachmentCache) get(key string) (string, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	attachment, ok := cache.items[key]
+	return attachment, ok
+}
+
+func (cache *AttachmentCache) put(key, attachment string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.items == nil {
+		cache.items = make(map[string]string)
+	}
+	cache.items[key] = attachment
+}
+
+func photoAttachment(photo object.PhotosPhoto) string {
+	attachment := photo.ToAttachment()
+	if photo.AccessKey != "" {
+		attachment += "_" + photo.AccessKey
+	}
+	return attachment
+}
+
+func docAttachment(ownerID, id int, accessKey string) string {
+	attachment := "doc" + strconv.Itoa(ownerID) + "_" + strconv.Itoa(id)
+	if accessKey != "" {
+		attachment += "_" + accessKey
+	}
+	return attachment
+}
+
+// Загрузка вложения через [Uploader]. Возвращает строку вложения для поля [OutgoingMessage.Attachments].
+func UploadAttachment(uploader Uploader, peerID int, upload Upload) (string, error) {
+	if uploader == nil {
+		return "", fmt.Errorf("upload %s: %w", upload.Type, ErrNoVK)
+	}
+	file := bytes.NewReader(upload.Data)
+	switch upload.Type {
+	case UploadPhoto:
+		photos, err := uploader.UploadMessagesPhoto(peerID, file)
+		if err != nil {
+			return "", fmt.Errorf("upload photo: %w", err)
+		}
+		if len(photos) == 0 {
+			return "", fmt.Errorf("upload photo: empty response")
+		}
+		return photoAttachment(photos[0]), nil
+	case UploadDocument, UploadAudioMessage, UploadGraffiti:
+		doc, err := uploader.UploadMessagesDoc(peerID, upload.Type.String(), upload.Name, "", file)
+		if err != nil {
+			return "", fmt.Errorf("upload %s: %w", upload.Type, err)
+		}
+		switch upload.Type {
+		case UploadAudioMessage:
+			return docAttachment(doc.AudioMessage.OwnerID, doc.AudioMessage.ID, doc.AudioMessage.AccessKey), nil
+		case UploadGraffiti:
+			return docAttachment(doc.Graffiti.OwnerID, doc.Graffiti.ID, doc.Graffiti.AccessKey), nil
+		}
+		return docAttachment(doc.Doc.OwnerID, doc.Doc.ID, doc.Doc.AccessKey), nil
+	}
+	return "", fmt.Errorf("upload %s: unsupported type", upload.Type)
+}
+
+func (ctx CommandContext[DEPS]) uploader() Uploader {
+	if ctx.commands != nil && ctx.commands.Uploader != nil {
+		return ctx.commands.Uploader
+	}
+	if ctx.VK == nil {
+		return nil
+	}
+	return ctx.VK
+}
+
+// Загрузка вложения для отправки в текущую беседу.
+//
+// Если в объекте команд задан [Commands.AttachmentCache], повторная загрузка файла с тем же содержимым и типом не выполняется,
+// а возвращается ранее полученное вложение.
+func (ctx CommandContext[DEPS]) Upload(upload Upload) (string, error) {
+	var cache *AttachmentCache
+	if ctx.commands != nil {
+		cache = ctx.commands.AttachmentCache
+	}
+	var key string
+	if cache != nil {
+		key = upload.hash()
+		if attachment, ok := cache.get(key); ok {
+			return attachment, nil
+		}
+	}
+
+	attachment, err := UploadAttachment(ctx.uploader(), ctx.Message.PeerID, upload)
+	if err != nil {
+		return "", err
+	}
+	if cache != nil {
+		cache.put(key, attachment)
+	}
+	return attachment, nil
+}
+
+// Загрузка файлов и отправка их одним сообщением вместе с текстом и клавиатурой (оба необязательны).
+//
+// Пример использования:
+//
+//	photo, err := UploadFromFile(UploadPhoto, "cat.jpg")
+//	if err != nil {
+//		return err
+//	}
+//	_, err = ctx.SendUploads("Держи котика", nil, photo)
+//	return err
+func (ctx CommandContext[DEPS]) SendUploads(text string, keyboard *object.MessagesKeyboard, uploads ...Upload) (SentMessages, error) {
+	msg := OutgoingMessage{Text: text, Keyboard: keyboard}
+	for _, upload := range uploads {
+		attachment, err := ctx.Upload(upload)
+		if err != nil {
+			return nil, err
+		}
+		msg.Attachments = append(msg.Attachments, attachment)
+	}
+	return ctx.SendMessage(msg)
+}
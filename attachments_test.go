@@ -0,0 +1,120 @@
+package vkc
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SevereCloud/vksdk/v3/api"
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+type fakeUploader struct {
+	photos int
+	docs   []string
+}
+
+func (u *fakeUploader) UploadMessagesPhoto(peerID int, file io.Reader) (api.PhotosSaveMessagesPhotoResponse, error) {
+	u.photos++
+	return api.PhotosSaveMessagesPhotoResponse{{ID: u.photos, OwnerID: -1, AccessKey: "key"}}, nil
+}
+
+func (u *fakeUploader) UploadMessagesDoc(peerID int, typeDoc, title, tags string, file io.Reader) (api.DocsSaveResponse, error) {
+	u.docs = append(u.docs, typeDoc+":"+title)
+	var resp api.DocsSaveResponse
+	resp.Type = typeDoc
+	switch typeDoc {
+	case "audio_message":
+		resp.AudioMessage.ID, resp.AudioMessage.OwnerID = 10, -1
+	case "graffiti":
+		resp.Graffiti.ID, resp.Graffiti.OwnerID = 20, -1
+	default:
+		resp.Doc.ID, resp.Doc.OwnerID = 30, -1
+	}
+	return resp, nil
+}
+
+type fakeSender struct {
+	sent []OutgoingMessage
+}
+
+func (s *fakeSender) Send(ctx context.Context, msg OutgoingMessage) (SentMessage, error) {
+	s.sent = append(s.sent, msg)
+	return SentMessage{PeerID: msg.PeerID, MessageID: len(s.sent), ConversationMessageID: len(s.sent)}, nil
+}
+
+func TestUploadAttachments(t *testing.T) {
+	uploader := &fakeUploader{}
+	sender := &fakeSender{}
+	commands := Commands[any]{
+		Sender:          sender,
+		Uploader:        uploader,
+		AttachmentCache: &AttachmentCache{},
+	}
+	ctx := CommandContext[any]{
+		Message:  object.MessagesMessage{PeerID: 2000000001, FromID: 1},
+		commands: &commands,
+	}
+
+	path := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(path, []byte("report"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := UploadFromFile(UploadDocument, path)
+	if err != nil {
+		t.Fatalf("UploadFromFile() error = %v", err)
+	}
+
+	keyboard := object.NewMessagesKeyboard(true)
+	_, err = ctx.SendUploads("Файлы", keyboard,
+		UploadFromBytes(UploadPhoto, "cat.jpg", []byte("cat")),
+		UploadFromBytes(UploadPhoto, "same-cat.jpg", []byte("cat")),
+		doc,
+		UploadFromBytes(UploadAudioMessage, "voice.ogg", []byte("voice")),
+		UploadFromBytes(UploadGraffiti, "graffiti.png", []byte("graffiti")),
+	)
+	if err != nil {
+		t.Fatalf("SendUploads() error = %v", err)
+	}
+
+	if uploader.photos != 1 {
+		t.Errorf("photos uploaded = %d, want 1 (second one from cache)", uploader.photos)
+	}
+	if len(sender.sent) != 1 {
+		t.Fatalf("messages sent = %d, want 1", len(sender.sent))
+	}
+	msg := sender.sent[0]
+	expected := []string{"photo-1_1_key", "photo-1_1_key", "doc-1_30", "doc-1_10", "doc-1_20"}
+	if len(msg.Attachments) != len(expected) {
+		t.Fatalf("attachments = %v, want %v", msg.Attachments, expected)
+	}
+	for i := range expected {
+		if msg.Attachments[i] != expected[i] {
+			t.Errorf("attachments[%d] = %q, want %q", i, msg.Attachments[i], expected[i])
+		}
+	}
+	if msg.Text != "Файлы" || msg.Keyboard != keyboard || msg.PeerID != 2000000001 {
+		t.Errorf("sent message = %+v", msg)
+	}
+	if uploader.docs[0] != "doc:report.txt" {
+		t.Errorf("document upload = %q, want %q", uploader.docs[0], "doc:report.txt")
+	}
+}
+
+func TestSendThroughSender(t *testing.T) {
+	sender := &fakeSender{}
+	commands := Commands[any]{Sender: sender}
+	ctx := CommandContext[any]{
+		Message:  object.MessagesMessage{ID: 5, PeerID: 1, FromID: 1},
+		commands: &commands,
+	}
+
+	if err := ctx.Reply("Привет, %s!", "мир"); err != nil {
+		t.Fatalf("Reply() error = %v", err)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].Text != "Привет, мир!" || sender.sent[0].ReplyTo != 5 {
+		t.Errorf("sent = %+v", sender.sent)
+	}
+}
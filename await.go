@@ -0,0 +1,141 @@
+package vkc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+// Условие для [CommandContext.Await]. Если возвращает false, сообщение обрабатывается как обычно.
+type AwaitMatcher func(msg object.MessagesMessage) bool
+
+type awaitEntry struct {
+	match AwaitMatcher
+	reply chan object.MessagesMessage
+}
+
+// Реестр ожидающих ответа обработчиков. Нулевое значение готово к использованию.
+//
+// Чтобы [CommandContext.Await] работал, в объекте команд должно быть задано поле [Commands.Awaiter]:
+//
+//	commands := Commands[any]{
+//		Prefix:  PrefixText("!"),
+//		Awaiter: &Awaiter{},
+//		// ...
+//	}
+//
+// Важно: пока обработчик ждет ответа, следующее сообщение должно быть обработано параллельно.
+// Если [Commands.ProcessCommands] вызывается синхронно из Long Poll, включите lp.Goroutine(true).
+type Awaiter struct {
+	mu      sync.Mutex
+	pending map[DialogKey][]*awaitEntry
+}
+
+func (awaiter *Awaiter) add(key DialogKey, match AwaitMatcher) *awaitEntry {
+	awaiter.mu.Lock()
+	defer awaiter.mu.Unlock()
+	if awaiter.pending == nil {
+		awaiter.pending = make(map[DialogKey][]*awaitEntry)
+	}
+	entry := &awaitEntry{match: match, reply: make(chan object.MessagesMessage, 1)}
+	awaiter.pending[key] = append(awaiter.pending[key], entry)
+	return entry
+}
+
+// Удаление ожидания. Возвращает false, если сообщение уже было передано ожидающему.
+func (awaiter *Awaiter) remove(key DialogKey, entry *awaitEntry) bool {
+	awaiter.mu.Lock()
+	defer awaiter.mu.Unlock()
+	entries := awaiter.pending[key]
+	for i, e := range entries {
+		if e == entry {
+			awaiter.removeAt(key, i)
+			return true
+		}
+	}
+	return false
+}
+
+func (awaiter *Awaiter) removeAt(key DialogKey, i int) {
+	entries := awaiter.pending[key]
+	entries = append(entries[:i:i], entries[i+1:]...)
+	if len(entries) == 0 {
+		delete(awaiter.pending, key)
+	} else {
+		awaiter.pending[key] = entries
+	}
+}
+
+// Передача сообщения первому подходящему ожидающему обработчику. Возвращает true, если сообщение было перехвачено.
+func (awaiter *Awaiter) deliver(msg object.MessagesMessage) bool {
+	key := DialogKey{PeerID: msg.PeerID, UserID: msg.FromID}
+	awaiter.mu.Lock()
+	defer awaiter.mu.Unlock()
+	for i, entry := range awaiter.pending[key] {
+		if entry.match != nil && !entry.match(msg) {
+			continue
+		}
+		awaiter.removeAt(key, i)
+		entry.reply <- msg
+		return true
+	}
+	return false
+}
+
+// Ожидание следующего сообщения от автора команды в той же беседе.
+//
+// Если указано условие match, перехватываются только подходящие под него сообщения, остальные обрабатываются как обычно.
+// Нулевой timeout означает ожидание без ограничения по времени (до отмены контекста [CommandContext.Context]).
+//
+// Пример использования:
+//
+//	if err := ctx.SendText("Вы уверены? да/нет"); err != nil {
+//		return err
+//	}
+//	answer, err := ctx.Await(30*time.Second, func(msg object.MessagesMessage) bool {
+//		return msg.Text == "да" || msg.Text == "нет"
+//	})
+//	if errors.Is(err, ErrAwaitTimeout) {
+//		return ctx.SendText("Время на ответ истекло.")
+//	}
+//
+// Возвращает [ErrAwaitUnavailable], если в объекте команд не задан [Commands.Awaiter], [ErrAwaitTimeout] по истечении времени
+// и ошибку контекста при его отмене.
+func (ctx CommandContext[DEPS]) Await(timeout time.Duration, match AwaitMatcher) (object.MessagesMessage, error) {
+	if ctx.commands == nil || ctx.commands.Awaiter == nil {
+		return object.MessagesMessage{}, ErrAwaitUnavailable
+	}
+
+	parent := ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	awaiter := ctx.commands.Awaiter
+	key := ctx.dialogKey()
+	entry := awaiter.add(key, match)
+
+	var err error
+	select {
+	case msg := <-entry.reply:
+		return msg, nil
+	case <-timeoutCh:
+		err = ErrAwaitTimeout
+	case <-parent.Done():
+		err = parent.Err()
+	}
+
+	if !awaiter.remove(key, entry) {
+		// сообщение пришло одновременно с отменой ожидания, не теряем его
+		return <-entry.reply, nil
+	}
+	return object.MessagesMessage{}, err
+}
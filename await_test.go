@@ -0,0 +1,132 @@
+package vkc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+func TestAwait(t *testing.T) {
+	answers := make(chan string, 1)
+	commands := Commands[any]{
+		Prefix:  PrefixText("!"),
+		Awaiter: &Awaiter{},
+		Handlers: []*CommandHandler[any]{
+			{
+				Pattern: Text("confirm"),
+				Executor: func(ctx CommandContext[any]) error {
+					msg, err := ctx.Await(time.Second, func(msg object.MessagesMessage) bool {
+						return msg.Text == "да" || msg.Text == "нет"
+					})
+					if err != nil {
+						return err
+					}
+					answers <- msg.Text
+					return nil
+				},
+			},
+		},
+	}
+	ctx := context.Background()
+
+	done := make(chan error, 1)
+	go func() { done <- commands.ProcessCommands(ctx, nil, newTestMessage(1, 1, "!confirm")) }()
+
+	// ждем регистрации ожидания
+	for {
+		commands.Awaiter.mu.Lock()
+		n := len(commands.Awaiter.pending)
+		commands.Awaiter.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := commands.ProcessCommands(ctx, nil, newTestMessage(1, 2, "да")); !errors.Is(err, ErrNoPrefix) {
+		t.Errorf("message from other user error = %v, want %v", err, ErrNoPrefix)
+	}
+	if err := commands.ProcessCommands(ctx, nil, newTestMessage(1, 1, "может быть")); !errors.Is(err, ErrNoPrefix) {
+		t.Errorf("unmatched message error = %v, want %v", err, ErrNoPrefix)
+	}
+	if err := commands.ProcessCommands(ctx, nil, newTestMessage(1, 1, "да")); err != nil {
+		t.Errorf("awaited message error = %v, want nil", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("executor error = %v, want nil", err)
+	}
+	if answer := <-answers; answer != "да" {
+		t.Errorf("awaited answer = %q, want %q", answer, "да")
+	}
+}
+
+func TestAwaitTimeoutAndCancel(t *testing.T) {
+	commands := Commands[any]{Awaiter: &Awaiter{}}
+	cmdCtx := CommandContext[any]{
+		Message:  object.MessagesMessage{PeerID: 1, FromID: 1},
+		commands: &commands,
+	}
+
+	if _, err := cmdCtx.Await(10*time.Millisecond, nil); !errors.Is(err, ErrAwaitTimeout) {
+		t.Errorf("Await() error = %v, want %v", err, ErrAwaitTimeout)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cmdCtx.Context = ctx
+	if _, err := cmdCtx.Await(0, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Await() error = %v, want %v", err, context.Canceled)
+	}
+
+	if len(commands.Awaiter.pending) != 0 {
+		t.Errorf("pending awaits = %d, want 0", len(commands.Awaiter.pending))
+	}
+	if _, err := (CommandContext[any]{}).Await(0, nil); !errors.Is(err, ErrAwaitUnavailable) {
+		t.Errorf("Await() without awaiter error = %v, want %v", err, ErrAwaitUnavailable)
+	}
+}
+
+func TestAwaitConcurrent(t *testing.T) {
+	commands := Commands[any]{Awaiter: &Awaiter{}}
+	const n = 50
+
+	var wg sync.WaitGroup
+	results := make(chan int, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cmdCtx := CommandContext[any]{
+				Message:  object.MessagesMessage{PeerID: i, FromID: 1},
+				commands: &commands,
+			}
+			msg, err := cmdCtx.Await(time.Second, nil)
+			if err == nil {
+				results <- msg.PeerID
+			}
+		}()
+	}
+
+	for i := 0; i < n; {
+		if commands.Awaiter.deliver(object.MessagesMessage{PeerID: i, FromID: 1}) {
+			i++
+		} else {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	wg.Wait()
+	close(results)
+
+	seen := make(map[int]bool)
+	for peerID := range results {
+		seen[peerID] = true
+	}
+	if len(seen) != n {
+		t.Errorf("delivered to %d awaits, want %d", len(seen), n)
+	}
+}
@@ -0,0 +1,59 @@
+package vkc
+
+import (
+	"sync"
+	"time"
+)
+
+// Источник текущего времени. Нулевое значение (nil) означает системные часы.
+//
+// Задается в [Commands.Clock] и [MemoryStore.Clock], чтобы сроки диалогов и записей хранилища
+// считались по управляемым часам, например при воспроизведении событий (см. [Replay]).
+type Clock func() time.Time
+
+func (clock Clock) now() time.Time {
+	if clock == nil {
+		return time.Now()
+	}
+	return clock()
+}
+
+// Часы, которые идут только вручную. Нулевое значение показывает нулевое время. Безопасны для конкурентного использования.
+//
+//	clock := &ManualClock{}
+//	clock.Set(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+//	commands.Clock = clock.Now
+//	clock.Add(time.Minute)
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// Текущее время часов.
+func (clock *ManualClock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+// Установка времени.
+func (clock *ManualClock) Set(now time.Time) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.now = now
+}
+
+// Перевод часов вперед на d.
+func (clock *ManualClock) Add(d time.Duration) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.now = clock.now.Add(d)
+}
+
+// Текущее время по часам объекта команд (см. [Commands.Clock]).
+func (ctx CommandContext[DEPS]) Now() time.Time {
+	if ctx.commands == nil {
+		return time.Now()
+	}
+	return ctx.commands.Clock.now()
+}
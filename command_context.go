@@ -1,21 +1,34 @@
 package vkc
 
 import (
+	"context"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/SevereCloud/vksdk/v3/api"
-	"github.com/SevereCloud/vksdk/v3/api/params"
 	"github.com/SevereCloud/vksdk/v3/events"
 	"github.com/SevereCloud/vksdk/v3/object"
 )
 
 // Контекст команды. Передается в каждый обработчик.
 type CommandContext[DEPS any] struct {
+	// Контекст, переданный в [Commands.ProcessCommands].
+	Context    context.Context
 	VK         *api.VK
 	Message    object.MessagesMessage
 	Arguments  []string
 	RawEvent   events.MessageNewObject
 	Dependency DEPS
+	// Правило, по которому сообщение было признано командой (с префиксом или без него, см. [PrefixPolicy]).
+	PrefixRule PrefixRule
+	// Команда вызвана изменением сообщения (событие message_edit, см. [Commands.ProcessEdit]).
+	Edited bool
+
+	commands *Commands[DEPS]
+	// ответы команды для CommandHandler.OnEdit = EditRerunAndEditReply
+	replies *replyTracker
+	// счетчик отправок для random_id, общий для всех копий контекста одного события
+	sendSeq *atomic.Int64
 }
 
 // Параметры отправки сообщения. Используется в методах Send и SendMessageRaw.
@@ -43,40 +56,39 @@ func WithFmtAndReplyParams(subst ...any) (ret *SendTextParams) {
 	return
 }
 
-// Базовый метод отправки сообщения.
-// Возвращает ошибки в случаях: ...
-func SendMessageRaw(vk *api.VK, msg *object.MessagesMessage, peerID int, text string, sendParams *SendTextParams) error {
+func newTextMessage(msg *object.MessagesMessage, peerID int, text string, sendParams *SendTextParams) OutgoingMessage {
 	if sendParams == nil {
 		sendParams = &SendTextParams{}
 	}
-	b := params.NewMessagesSendBuilder()
+	out := OutgoingMessage{PeerID: peerID, Text: text}
 	if sendParams.Reply && msg != nil {
-		b.ReplyTo(msg.ID)
+		out.ReplyTo = msg.ID
 	}
-	finalText := text
 	if len(sendParams.Fmt) > 0 {
-		finalText = fmt.Sprintf(finalText, sendParams.Fmt...)
-	}
-	b.Message(finalText)
-	b.RandomID(0)
-	b.PeerID(peerID)
-	if _, err := vk.MessagesSend(b.Params); err != nil {
-		return fmt.Errorf("send error: %v", err)
+		out.Text = fmt.Sprintf(text, sendParams.Fmt...)
 	}
-	return nil
+	return out
+}
+
+// Базовый метод отправки сообщения.
+// Возвращает ошибки в случаях: ...
+func SendMessageRaw(vk *api.VK, msg *object.MessagesMessage, peerID int, text string, sendParams *SendTextParams) error {
+	_, err := SendSplit(context.Background(), VKSender{VK: vk}, newTextMessage(msg, peerID, text, sendParams))
+	return err
 }
 
 // Отправка сообщения с параметрами.
 func (ctx CommandContext[DEPS]) Send(text string, sendParams *SendTextParams) error {
-	return SendMessageRaw(ctx.VK, &ctx.Message, ctx.Message.PeerID, text, sendParams)
+	_, err := ctx.SendMessage(newTextMessage(&ctx.Message, ctx.Message.PeerID, text, sendParams))
+	return err
 }
 
 // Отправка сообщения с форматированием.
 func (ctx CommandContext[DEPS]) SendText(text string, fmts ...any) error {
-	return SendMessageRaw(ctx.VK, &ctx.Message, ctx.Message.PeerID, text, WithFmtParams(fmts...))
+	return ctx.Send(text, WithFmtParams(fmts...))
 }
 
 // Отправка ответа на команду с форматированием.
 func (ctx CommandContext[DEPS]) Reply(text string, fmts ...any) error {
-	return SendMessageRaw(ctx.VK, &ctx.Message, ctx.Message.PeerID, text, WithFmtAndReplyParams(fmts...))
+	return ctx.Send(text, WithFmtAndReplyParams(fmts...))
 }
@@ -1,5 +1,9 @@
 package vkc
 
+import (
+	"slices"
+)
+
 // Функция обработчика команды. Получает контекст и возвращает ошибку или nil.
 type HandlerFunc[DEPS any] func(ctx CommandContext[DEPS]) error
 
@@ -39,10 +43,38 @@ type HandlerAccessCheck[DEPS any] struct {
 //		Executor: func(ctx CommandContext[DepsType]) error { /* логика команды */ },
 //	}
 type CommandHandler[DEPS any] struct {
+	// Шаблон команды, общий для всех языков. Может быть пустым, если заданы Names.
 	Pattern     CommandPattern
 	Help        CommandHelp
 	AccessCheck *HandlerAccessCheck[DEPS]
 	Executor    HandlerFunc[DEPS]
+	// Статус активности ("печатает…"), который показывается в беседе, пока выполняется Executor. Если пуст, статус не отправляется.
+	Activity Activity
+	// Названия команды по языкам (см. [CommandContext.Locale]). Например, {"ru": {"помощь"}, "en": {"help", "h"}}:
+	// в русскоязычной беседе команда вызывается как "помощь", в англоязычной — как "help" или "h".
+	// Названия проверяются в дополнение к Pattern, только для языка автора сообщения; если для него названий нет —
+	// для языка по умолчанию ([I18n.Default]).
+	Names map[string][]string
+	// Выполнять команду и для повторно доставленных событий (см. [Deduplicator]). Подходит для команд, повтор которых безопасен.
+	AllowDuplicates bool
+	// Поведение при изменении сообщения с командой (см. [Commands.ProcessEdit]). По умолчанию изменения игнорируются.
+	OnEdit EditPolicy
+}
+
+// Названия команды для языка locale, а если для него названий нет — для языка по умолчанию defaultLocale.
+func (handler *CommandHandler[DEPS]) localeNames(locale, defaultLocale string) []string {
+	if names, ok := handler.Names[locale]; ok {
+		return names
+	}
+	return handler.Names[defaultLocale]
+}
+
+// Проверка совпадения названия команды с шаблоном или с названиями для языка locale (см. localeNames).
+func (handler *CommandHandler[DEPS]) matches(candidate, locale, defaultLocale string) bool {
+	if handler.Pattern != nil && handler.Pattern(candidate) {
+		return true
+	}
+	return slices.Contains(handler.localeNames(locale, defaultLocale), candidate)
 }
 
 // Метод для проверки доступности команды для пользователя.
@@ -12,6 +12,9 @@ package vkc
 //		Usage:   "!hello [имя]",
 //		Aliases: "hello, hi",
 //		Hidden:  false,
+//		Localized: map[string]CommandHelp{
+//			"en": {Brief: "Greets the user", Usage: "!hello [name]"},
+//		},
 //	}
 type CommandHelp struct {
 	Title   string
@@ -19,4 +22,30 @@ type CommandHelp struct {
 	Usage   string
 	Aliases string
 	Hidden  bool
+	// Переводы помощи по языкам. Пустые поля перевода берутся из основной помощи.
+	Localized map[string]CommandHelp
+}
+
+// Помощь на указанном языке: основная помощь, в которой заменены непустые поля перевода.
+// Если перевода нет, возвращается основная помощь.
+func (help CommandHelp) For(locale string) CommandHelp {
+	localized, ok := help.Localized[locale]
+	result := help
+	result.Localized = nil
+	if !ok {
+		return result
+	}
+	if localized.Title != "" {
+		result.Title = localized.Title
+	}
+	if localized.Brief != "" {
+		result.Brief = localized.Brief
+	}
+	if localized.Usage != "" {
+		result.Usage = localized.Usage
+	}
+	if localized.Aliases != "" {
+		result.Aliases = localized.Aliases
+	}
+	return result
 }
@@ -1,6 +1,7 @@
 package vkc
 
 import (
+	"fmt"
 	"regexp"
 	"slices"
 )
@@ -52,9 +53,34 @@ var Regex CommandPatternProvider[*regexp.Regexp] = func(matcher *regexp.Regexp)
 // Если нужно сгруппировать части регулярки, то следует использовать группы без захвата (т.е. (?:...)).
 //
 // Из-за MustCompile может вызывать панику, если регулярное выражение составлено некорректно. Это поведение нельзя переопределить.
+// Чтобы получить ошибку вместо паники, используйте [CompileRegex].
 var RegexStr CommandPatternProvider[string] = func(matcher string) CommandPattern {
 	re := regexp.MustCompile(matcher)
 	return func(input string) bool {
 		return re.Match([]byte(input))
 	}
 }
+
+// Создание шаблона команды из *скомпилированного* регулярного выражения с проверкой на nil.
+func NewRegex(re *regexp.Regexp) (CommandPattern, error) {
+	if re == nil {
+		return nil, fmt.Errorf("command regex: %w", ErrNilRegex)
+	}
+	return Regex(re), nil
+}
+
+// Создание шаблона команды из *строкового* регулярного выражения. В отличие от [RegexStr], не паникует, а возвращает ошибку компиляции.
+//
+// Пример использования:
+//
+//	pattern, err := CompileRegex(`^user list$`)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func CompileRegex(expr string) (CommandPattern, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("command regex: %w", err)
+	}
+	return Regex(re), nil
+}
@@ -1,6 +1,7 @@
 package vkc
 
 import (
+	"errors"
 	"regexp"
 	"testing"
 )
@@ -204,3 +205,20 @@ func TestRegexCmd(t *testing.T) {
 		})
 	}
 }
+
+func TestCompileRegex(t *testing.T) {
+	pattern, err := CompileRegex(`^user list$`)
+	if err != nil {
+		t.Fatalf("CompileRegex() error = %v", err)
+	}
+	if !pattern("user list") {
+		t.Error("CompileRegex(`^user list$`)(\"user list\") = false, want true")
+	}
+
+	if _, err := CompileRegex(`^user (`); err == nil {
+		t.Error("CompileRegex() with invalid syntax error = nil")
+	}
+	if _, err := NewRegex(nil); !errors.Is(err, ErrNilRegex) {
+		t.Errorf("NewRegex(nil) error = %v, want %v", err, ErrNilRegex)
+	}
+}
@@ -1,13 +1,37 @@
 package vkc
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/SevereCloud/vksdk/v3/object"
 )
 
 // Функция для проверки префикса команды. Возвращает успешность совпадения и остаток (сама команда и ее аргументы).
 type PrefixMatcher func(input string) (matched bool, remaining string)
 
+// Контекст для проверки префикса: беседа, автор и само сообщение.
+type PrefixContext struct {
+	PeerID  int
+	FromID  int
+	Message object.MessagesMessage
+}
+
+// Функция для проверки префикса команды с учетом контекста сообщения. Позволяет, например, задавать свой префикс в каждой беседе.
+//
+// Используется в поле [Commands.ContextPrefix].
+type ContextPrefixMatcher func(pctx PrefixContext, input string) (matched bool, remaining string)
+
+// Преобразование обычного PrefixMatcher в ContextPrefixMatcher, который игнорирует контекст.
+func (matcher PrefixMatcher) WithContext() ContextPrefixMatcher {
+	return func(_ PrefixContext, input string) (bool, string) {
+		return matcher(input)
+	}
+}
+
 // Провайдер для создания PrefixMatcher из значения. Используется объектом команд и обработчиком нового сообщения.
 //
 // Пример использования (см. соответствующие провайдеры):
@@ -52,19 +76,13 @@ var PrefixListOf PrefixMatcherProvider[[]string] = func(matcher []string) Prefix
 //
 // Важно: в группу с индексом 1 обязательно должен попасть остаток, т.е. все, что идет после префикса.
 // Если нужно сгруппировать части регулярки, то следует использовать группы без захвата (т.е. (?:...)).
+// Если группы нет, остатком считается весь текст после совпадения. Чтобы проверить наличие группы при запуске, используйте [NewPrefixRegex].
 //
 // Также рекомендуется включить в регулярное выражение границу начала строки и отключить чувствительность к регистру символов. Например, так:
 //
 //	PrefixRegex(regex.MustCompile(`(?i)^вашерегулярноевыражение`))
 var PrefixRegex PrefixMatcherProvider[*regexp.Regexp] = func(matcher *regexp.Regexp) PrefixMatcher {
-	return func(input string) (bool, string) {
-		matches := matcher.FindStringSubmatch(input)
-		if matches == nil {
-			return false, ""
-		}
-		remaining := strings.TrimSpace(matches[1])
-		return true, remaining
-	}
+	return regexPrefixMatcher(matcher)
 }
 
 // Провайдер для поиска совпадений *строковым* регулярным выражением. Само выражение компилируется внутри провайдера.
@@ -77,25 +95,174 @@ var PrefixRegex PrefixMatcherProvider[*regexp.Regexp] = func(matcher *regexp.Reg
 //	PrefixRegexStr(`(?i)^вашерегулярноевыражение`)
 //
 // Из-за MustCompile может вызывать панику, если регулярное выражение составлено некорректно. Это поведение нельзя переопределить.
+// Чтобы получить ошибку вместо паники, используйте [CompilePrefixRegex].
 var PrefixRegexStr PrefixMatcherProvider[string] = func(matcher string) PrefixMatcher {
-	re := regexp.MustCompile(matcher)
+	return regexPrefixMatcher(regexp.MustCompile(matcher))
+}
+
+func regexPrefixMatcher(re *regexp.Regexp) PrefixMatcher {
 	return func(input string) (bool, string) {
-		matches := re.FindStringSubmatch(input)
-		if matches == nil {
+		loc := re.FindStringSubmatchIndex(input)
+		if loc == nil {
 			return false, ""
 		}
-		remaining := strings.TrimSpace(matches[1])
-		return true, remaining
+		if len(loc) < 4 {
+			return true, strings.TrimSpace(input[loc[1]:])
+		}
+		if loc[2] < 0 {
+			return true, ""
+		}
+		return true, strings.TrimSpace(input[loc[2]:loc[3]])
 	}
 }
 
+// Создание префикса из *скомпилированного* регулярного выражения с проверкой. В отличие от [PrefixRegex],
+// возвращает ошибку [ErrRegexNoCaptureGroup], если в выражении нет группы для остатка.
+func NewPrefixRegex(re *regexp.Regexp) (PrefixMatcher, error) {
+	if re == nil {
+		return nil, fmt.Errorf("prefix regex: %w", ErrNilRegex)
+	}
+	if re.NumSubexp() < 1 {
+		return nil, fmt.Errorf("prefix regex %q: %w", re.String(), ErrRegexNoCaptureGroup)
+	}
+	return regexPrefixMatcher(re), nil
+}
+
+// Создание префикса из *строкового* регулярного выражения. В отличие от [PrefixRegexStr], не паникует,
+// а возвращает ошибку компиляции или [ErrRegexNoCaptureGroup], если в выражении нет группы для остатка.
+//
+// Пример использования:
+//
+//	prefix, err := CompilePrefixRegex(`(?i)^(?:эй\s)?бот(.*)`)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func CompilePrefixRegex(expr string) (PrefixMatcher, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("prefix regex: %w", err)
+	}
+	return NewPrefixRegex(re)
+}
+
 // Провайдер для поиска совпадений с помощью функции.
 //
 // Функция должна возвращать два значения: булев (найден ли префикс в строке) и строку (весь текст после префикса).
 //
-// Изначально задумывалось, что провайдер будет получать дополнительный контекст для кастомизации префикса, но пока что данный функционал отсутствует.
-//
-// TODO: https://github.com/EgorBron/vkc/issues/4
+// Для префикса, зависящего от беседы или пользователя, используйте [PrefixContextFunc] и поле [Commands.ContextPrefix].
 var PrefixFunc PrefixMatcherProvider[func(string) (bool, string)] = func(matcher func(string) (bool, string)) PrefixMatcher {
 	return matcher
 }
+
+// Провайдер для поиска совпадений с помощью функции, получающей контекст сообщения (см. https://github.com/EgorBron/vkc/issues/4).
+//
+// Пример использования:
+//
+//	ContextPrefix: PrefixContextFunc(func(pctx PrefixContext, input string) (bool, string) {
+//		if pctx.PeerID == 2000000001 {
+//			return PrefixText("/")(input)
+//		}
+//		return PrefixText("!")(input)
+//	})
+func PrefixContextFunc(matcher func(pctx PrefixContext, input string) (bool, string)) ContextPrefixMatcher {
+	return matcher
+}
+
+// Объединение нескольких префиксов. Срабатывает первый совпавший префикс в порядке перечисления.
+func PrefixAny(matchers ...PrefixMatcher) PrefixMatcher {
+	return func(input string) (bool, string) {
+		for _, matcher := range matchers {
+			if matcher == nil {
+				continue
+			}
+			if matched, remaining := matcher(input); matched {
+				return true, remaining
+			}
+		}
+		return false, ""
+	}
+}
+
+// Поиск части входной строки, поглощенной префиксом (все, что до остатка). Возвращает false, если остаток не удалось найти во входной строке.
+func prefixConsumed(input, remaining string) (string, bool) {
+	trimmed := strings.TrimRightFunc(input, unicode.IsSpace)
+	if !strings.HasSuffix(trimmed, remaining) {
+		return "", false
+	}
+	return trimmed[:len(trimmed)-len(remaining)], true
+}
+
+// Префикс без учета регистра символов. Исходный префикс должен быть записан в нижнем регистре, остаток возвращается в исходном регистре.
+//
+// Пример использования:
+//
+//	Prefix: PrefixIgnoreCase(PrefixListOf([]string{"бот", "bot"})) // сработает на "Бот help" и "BOT help"
+func PrefixIgnoreCase(matcher PrefixMatcher) PrefixMatcher {
+	return func(input string) (bool, string) {
+		// переводим в нижний регистр только руны, не меняющие длину в байтах, чтобы смещения совпадали с исходной строкой
+		lowered := strings.Map(func(r rune) rune {
+			if lower := unicode.ToLower(r); utf8.RuneLen(lower) == utf8.RuneLen(r) {
+				return lower
+			}
+			return r
+		}, input)
+
+		matched, remaining := matcher(lowered)
+		if !matched {
+			return false, ""
+		}
+		consumed, ok := prefixConsumed(lowered, remaining)
+		if !ok {
+			return true, remaining
+		}
+		return true, strings.TrimSpace(input[len(consumed):])
+	}
+}
+
+// Префикс, после которого обязательно должен идти пробельный символ (или конец сообщения).
+//
+// Например, PrefixRequireSpace(PrefixText("бот")) сработает на "бот help", но не на "ботhelp".
+func PrefixRequireSpace(matcher PrefixMatcher) PrefixMatcher {
+	return func(input string) (bool, string) {
+		matched, remaining := matcher(input)
+		if !matched || remaining == "" {
+			return matched, remaining
+		}
+		consumed, ok := prefixConsumed(input, remaining)
+		if !ok {
+			return true, remaining
+		}
+		last, _ := utf8.DecodeLastRuneInString(consumed)
+		if !unicode.IsSpace(last) {
+			return false, ""
+		}
+		return true, remaining
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Префикс, который должен заканчиваться на границе слова: если префикс оканчивается буквой или цифрой, за ним не может сразу идти буква или цифра.
+//
+// Например, PrefixWordBoundary(PrefixText("бот")) сработает на "бот help" и "бот, help", но не на "ботик help".
+// Префиксы из знаков препинания ("!", "/") такой проверкой не ограничиваются.
+func PrefixWordBoundary(matcher PrefixMatcher) PrefixMatcher {
+	return func(input string) (bool, string) {
+		matched, remaining := matcher(input)
+		if !matched || remaining == "" {
+			return matched, remaining
+		}
+		consumed, ok := prefixConsumed(input, remaining)
+		if !ok {
+			return true, remaining
+		}
+		last, _ := utf8.DecodeLastRuneInString(consumed)
+		first, _ := utf8.DecodeRuneInString(remaining)
+		if isWordRune(last) && isWordRune(first) {
+			return false, ""
+		}
+		return true, remaining
+	}
+}
@@ -0,0 +1,66 @@
+package vkc
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/SevereCloud/vksdk/v3/api"
+)
+
+// Данные сообщества для поиска упоминаний бота. Обычно получаются через [ResolveMention].
+type Mention struct {
+	GroupID    int
+	ScreenName string
+}
+
+// Получение ID и короткого имени сообщества, от имени которого работает бот (через метод groups.getById).
+//
+// Следует вызывать один раз при запуске бота, а не при обработке каждого сообщения.
+func ResolveMention(vk *api.VK) (Mention, error) {
+	groups, err := vk.GroupsGetByID(api.Params{})
+	if err != nil {
+		return Mention{}, fmt.Errorf("resolve mention: %w", err)
+	}
+	if len(groups.Groups) == 0 {
+		return Mention{}, fmt.Errorf("resolve mention: groups.getById returned no groups")
+	}
+	return Mention{GroupID: groups.Groups[0].ID, ScreenName: groups.Groups[0].ScreenName}, nil
+}
+
+// Провайдер для упоминания сообщества в качестве префикса.
+//
+// Понимает разметку упоминания, которую VK вставляет автоматически ("[club123|@mybot] help", "[club123|Мой бот], help"),
+// а также упоминание текстом: "@mybot help", "@club123 help". После упоминания допускаются запятая или двоеточие.
+//
+// Пример использования вместе с обычным префиксом:
+//
+//	mention, err := ResolveMention(vk)
+//	// ...
+//	Prefix: PrefixAny(PrefixMentionOf(mention), PrefixText("!"))
+var PrefixMentionOf PrefixMatcherProvider[Mention] = func(mention Mention) PrefixMatcher {
+	id := strconv.Itoa(mention.GroupID)
+	names := []string{regexp.QuoteMeta("club" + id), regexp.QuoteMeta("public" + id)}
+	if mention.ScreenName != "" {
+		names = append(names, regexp.QuoteMeta(mention.ScreenName))
+	}
+
+	re := regexp.MustCompile(`(?is)^(?:\[(?:club|public)` + id + `\|[^\]]*\]|@(?:` + strings.Join(names, "|") + `))(?:$|[\s,:]+(.*)$)`)
+	return func(input string) (bool, string) {
+		matches := re.FindStringSubmatch(input)
+		if matches == nil {
+			return false, ""
+		}
+		return true, strings.TrimSpace(matches[1])
+	}
+}
+
+// Провайдер для упоминания сообщества в качестве префикса с получением его данных через VK API (см. [ResolveMention] и [PrefixMentionOf]).
+func PrefixMention(vk *api.VK) (PrefixMatcher, error) {
+	mention, err := ResolveMention(vk)
+	if err != nil {
+		return nil, err
+	}
+	return PrefixMentionOf(mention), nil
+}
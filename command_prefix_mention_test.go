@@ -0,0 +1,106 @@
+package vkc
+
+import (
+	"testing"
+)
+
+func TestPrefixMentionOf(t *testing.T) {
+	mention := Mention{GroupID: 123, ScreenName: "mybot"}
+	tests := []struct {
+		name           string
+		input          string
+		expectedMatch  bool
+		expectedRemain string
+	}{
+		{
+			name:           "markup with screen name",
+			input:          "[club123|@mybot] help",
+			expectedMatch:  true,
+			expectedRemain: "help",
+		},
+		{
+			name:           "markup with title and comma",
+			input:          "[club123|Мой бот], help me",
+			expectedMatch:  true,
+			expectedRemain: "help me",
+		},
+		{
+			name:           "public markup",
+			input:          "[public123|бот] help",
+			expectedMatch:  true,
+			expectedRemain: "help",
+		},
+		{
+			name:           "plain screen name",
+			input:          "@mybot help",
+			expectedMatch:  true,
+			expectedRemain: "help",
+		},
+		{
+			name:           "plain screen name case insensitive",
+			input:          "@MyBot: help",
+			expectedMatch:  true,
+			expectedRemain: "help",
+		},
+		{
+			name:           "plain club id",
+			input:          "@club123 help",
+			expectedMatch:  true,
+			expectedRemain: "help",
+		},
+		{
+			name:           "mention only",
+			input:          "[club123|@mybot]",
+			expectedMatch:  true,
+			expectedRemain: "",
+		},
+		{
+			name:          "other community",
+			input:         "[club1234|@other] help",
+			expectedMatch: false,
+		},
+		{
+			name:          "longer screen name",
+			input:         "@mybotx help",
+			expectedMatch: false,
+		},
+		{
+			name:          "user mention",
+			input:         "[id123|Иван] help",
+			expectedMatch: false,
+		},
+	}
+
+	matcher := PrefixMentionOf(mention)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, remaining := matcher(tt.input)
+			if matched != tt.expectedMatch {
+				t.Errorf("PrefixMentionOf(%v)(%q) matched = %v, want %v", mention, tt.input, matched, tt.expectedMatch)
+			}
+			if remaining != tt.expectedRemain {
+				t.Errorf("PrefixMentionOf(%v)(%q) remaining = %q, want %q", mention, tt.input, remaining, tt.expectedRemain)
+			}
+		})
+	}
+}
+
+func TestPrefixAny(t *testing.T) {
+	matcher := PrefixAny(PrefixMentionOf(Mention{GroupID: 1}), nil, PrefixText("!"))
+	tests := []struct {
+		input          string
+		expectedMatch  bool
+		expectedRemain string
+	}{
+		{input: "[club1|бот] help", expectedMatch: true, expectedRemain: "help"},
+		{input: "!help", expectedMatch: true, expectedRemain: "help"},
+		{input: "help", expectedMatch: false},
+	}
+
+	for _, tt := range tests {
+		matched, remaining := matcher(tt.input)
+		if matched != tt.expectedMatch || remaining != tt.expectedRemain {
+			t.Errorf("PrefixAny(...)(%q) = %v, %q, want %v, %q", tt.input, matched, remaining, tt.expectedMatch, tt.expectedRemain)
+		}
+	}
+}
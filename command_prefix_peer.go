@@ -0,0 +1,123 @@
+package vkc
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/SevereCloud/vksdk/v3/api"
+)
+
+// Максимальная длина префикса беседы в символах.
+const MaxPeerPrefixLength = 10
+
+// Префиксы, настраиваемые для каждой беседы отдельно. Хранятся в [Store] в пространстве имен "prefix".
+//
+// Пример использования:
+//
+//	prefixes := &PeerPrefixes{Store: store, Default: "!"}
+//	commands := Commands[any]{
+//		ContextPrefix: prefixes.Matcher(),
+//		Handlers: []*CommandHandler[any]{
+//			SetPrefixHandler[any](prefixes),
+//			// ...
+//		},
+//	}
+type PeerPrefixes struct {
+	Store Store
+	// Префикс для бесед, в которых он не был изменен.
+	Default string
+}
+
+func (prefixes *PeerPrefixes) store() Store {
+	if prefixes.Store == nil {
+		return unavailableStore{}
+	}
+	return Namespace(prefixes.Store, "prefix")
+}
+
+// Получение префикса беседы. Если префикс не был изменен, возвращается [PeerPrefixes.Default].
+func (prefixes *PeerPrefixes) Get(peerID int) (string, error) {
+	value, ok, err := prefixes.store().Get(strconv.Itoa(peerID))
+	if err != nil || !ok {
+		return prefixes.Default, err
+	}
+	return string(value), nil
+}
+
+// Изменение префикса беседы. Пустая строка или префикс по умолчанию сбрасывают настройку.
+func (prefixes *PeerPrefixes) Set(peerID int, prefix string) error {
+	if prefix == "" || prefix == prefixes.Default {
+		return prefixes.store().Delete(strconv.Itoa(peerID))
+	}
+	return prefixes.store().Set(strconv.Itoa(peerID), []byte(prefix), 0)
+}
+
+// Функция проверки префикса для поля [Commands.ContextPrefix]. Если хранилище недоступно, используется префикс по умолчанию.
+func (prefixes *PeerPrefixes) Matcher() ContextPrefixMatcher {
+	return func(pctx PrefixContext, input string) (bool, string) {
+		prefix, _ := prefixes.Get(pctx.PeerID)
+		return PrefixText(prefix)(input)
+	}
+}
+
+// Проверка, является ли пользователь администратором или создателем беседы. В ЛС сообщества пользователь всегда считается администратором.
+//
+// Для проверки в беседе бот должен быть ее администратором, иначе VK вернет ошибку.
+func IsChatAdmin(vk *api.VK, peerID, userID int) (bool, error) {
+	if !IsChatPeer(peerID) {
+		return peerID == userID, nil
+	}
+	members, err := vk.MessagesGetConversationMembers(api.Params{"peer_id": peerID})
+	if err != nil {
+		return false, err
+	}
+	for _, member := range members.Items {
+		if member.MemberID == userID {
+			return bool(member.IsAdmin) || bool(member.IsOwner), nil
+		}
+	}
+	return false, nil
+}
+
+// Проверка доступа "только для администраторов беседы" (см. [IsChatAdmin]). Ошибки VK API считаются отказом в доступе.
+func ChatAdminCheck[DEPS any]() *HandlerAccessCheck[DEPS] {
+	return &HandlerAccessCheck[DEPS]{
+		Checker: func(handler *CommandHandler[DEPS], ctx CommandContext[DEPS]) bool {
+			ok, err := IsChatAdmin(ctx.VK, ctx.Message.PeerID, ctx.Message.FromID)
+			return err == nil && ok
+		},
+	}
+}
+
+// Готовый обработчик команды "setprefix" для смены префикса в беседе. Доступен только администраторам беседы.
+//
+// Использование: "!setprefix /" меняет префикс на "/", "!setprefix" без аргументов сбрасывает его на префикс по умолчанию.
+func SetPrefixHandler[DEPS any](prefixes *PeerPrefixes) *CommandHandler[DEPS] {
+	return &CommandHandler[DEPS]{
+		Pattern:     Text("setprefix"),
+		Help:        builtinHelp("setprefix"),
+		AccessCheck: ChatAdminCheck[DEPS](),
+		Executor: func(ctx CommandContext[DEPS]) error {
+			prefix := strings.Join(ctx.Arguments, " ")
+			if utf8.RuneCountInString(prefix) > MaxPeerPrefixLength {
+				text, err := ctx.Translate("vkc.setprefix.too_long", Vars{"count": MaxPeerPrefixLength})
+				if err != nil {
+					return err
+				}
+				return ctx.Send(text, WithReplyParams)
+			}
+			if err := prefixes.Set(ctx.Message.PeerID, prefix); err != nil {
+				return err
+			}
+			if prefix == "" {
+				prefix = prefixes.Default
+			}
+			text, err := ctx.Translate("vkc.setprefix.changed", Vars{"prefix": prefix})
+			if err != nil {
+				return err
+			}
+			return ctx.Send(text, WithReplyParams)
+		},
+	}
+}
@@ -0,0 +1,105 @@
+package vkc
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPeerPrefixes(t *testing.T) {
+	prefixes := &PeerPrefixes{Store: NewMemoryStore(), Default: "!"}
+	if err := prefixes.Set(2000000001, "/"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		peerID         int
+		input          string
+		expectedMatch  bool
+		expectedRemain string
+	}{
+		{
+			name:           "custom prefix",
+			peerID:         2000000001,
+			input:          "/help",
+			expectedMatch:  true,
+			expectedRemain: "help",
+		},
+		{
+			name:          "default prefix in customized chat",
+			peerID:        2000000001,
+			input:         "!help",
+			expectedMatch: false,
+		},
+		{
+			name:           "default prefix",
+			peerID:         2000000002,
+			input:          "!help",
+			expectedMatch:  true,
+			expectedRemain: "help",
+		},
+	}
+
+	matcher := prefixes.Matcher()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, remaining := matcher(PrefixContext{PeerID: tt.peerID}, tt.input)
+			if matched != tt.expectedMatch {
+				t.Errorf("Matcher()(%d, %q) matched = %v, want %v", tt.peerID, tt.input, matched, tt.expectedMatch)
+			}
+			if remaining != tt.expectedRemain {
+				t.Errorf("Matcher()(%d, %q) remaining = %q, want %q", tt.peerID, tt.input, remaining, tt.expectedRemain)
+			}
+		})
+	}
+
+	if err := prefixes.Set(2000000001, ""); err != nil {
+		t.Fatalf("Set() reset error = %v", err)
+	}
+	if prefix, _ := prefixes.Get(2000000001); prefix != "!" {
+		t.Errorf("Get() after reset = %q, want %q", prefix, "!")
+	}
+}
+
+func TestContextPrefixInProcessCommands(t *testing.T) {
+	called := 0
+	commands := Commands[any]{
+		Prefix: PrefixText("!"),
+		ContextPrefix: PrefixContextFunc(func(pctx PrefixContext, input string) (bool, string) {
+			if pctx.PeerID == 1 {
+				return PrefixText("/")(input)
+			}
+			return PrefixText("#")(input)
+		}),
+		Handlers: []*CommandHandler[any]{
+			{
+				Pattern:  Text("ping"),
+				Executor: func(ctx CommandContext[any]) error { called++; return nil },
+			},
+		},
+	}
+
+	ctx := context.Background()
+	if err := commands.ProcessCommands(ctx, nil, newTestMessage(1, 1, "/ping")); err != nil {
+		t.Errorf("ProcessCommands(/ping) error = %v", err)
+	}
+	if err := commands.ProcessCommands(ctx, nil, newTestMessage(1, 1, "!ping")); !errors.Is(err, ErrNoPrefix) {
+		t.Errorf("ProcessCommands(!ping) error = %v, want %v", err, ErrNoPrefix)
+	}
+	if err := commands.ProcessCommands(ctx, nil, newTestMessage(2, 2, "#ping")); err != nil {
+		t.Errorf("ProcessCommands(#ping) error = %v", err)
+	}
+	if called != 2 {
+		t.Errorf("executor called %d times, want 2", called)
+	}
+}
+
+func TestIsChatAdminInDirectMessages(t *testing.T) {
+	if ok, err := IsChatAdmin(nil, 1, 1); !ok || err != nil {
+		t.Errorf("IsChatAdmin(1, 1) = %v, %v, want true, nil", ok, err)
+	}
+	if ok, _ := IsChatAdmin(nil, 1, 2); ok {
+		t.Error("IsChatAdmin(1, 2) = true, want false")
+	}
+}
@@ -0,0 +1,70 @@
+package vkc
+
+import (
+	"context"
+
+	"github.com/SevereCloud/vksdk/v3/events"
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+// Правило, по которому сообщение было признано командой. Записывается в [CommandContext.PrefixRule].
+type PrefixRule int
+
+const (
+	// В начале сообщения найден префикс.
+	PrefixRuleMatched PrefixRule = iota
+	// Префикс не найден, но сообщение отправлено в ЛС сообщества, где префикс необязателен (см. [PrefixPolicy.OptionalInDirect]).
+	PrefixRuleDirect
+	// Префикс не найден, но сообщение является ответом на сообщение бота в беседе (см. [PrefixPolicy.ReplyToBot]).
+	PrefixRuleReplyToBot
+)
+
+// Правила, по которым сообщение без префикса все равно считается командой. По умолчанию префикс обязателен везде.
+//
+// Пример использования:
+//
+//	commands := Commands[any]{
+//		Prefix: PrefixText("!"),
+//		PrefixPolicy: PrefixPolicy{
+//			OptionalInDirect: true, // в ЛС можно писать просто "help"
+//			ReplyToBot:       true, // в беседе можно ответить на сообщение бота текстом "help"
+//		},
+//	}
+type PrefixPolicy struct {
+	// Префикс необязателен в ЛС сообщества (peer_id пользователя, см. [IsUserPeer]).
+	OptionalInDirect bool
+	// Ответ на сообщение бота в беседе считается командой без префикса.
+	ReplyToBot bool
+	// ID сообщества бота для проверки ответов. Если не указан, берется из контекста события VK SDK.
+	GroupID int
+}
+
+// Проверка, можно ли считать сообщение без префикса командой. Возвращает правило, по которому это разрешено.
+func (policy PrefixPolicy) allows(ctx context.Context, msg object.MessagesMessage) (PrefixRule, bool) {
+	if policy.OptionalInDirect && IsUserPeer(msg.PeerID) {
+		return PrefixRuleDirect, true
+	}
+	if policy.ReplyToBot && IsChatPeer(msg.PeerID) && msg.ReplyMessage != nil {
+		groupID := policy.GroupID
+		if groupID == 0 {
+			groupID = groupIDFromContext(ctx)
+		}
+		if groupID != 0 && msg.ReplyMessage.FromID == -groupID {
+			return PrefixRuleReplyToBot, true
+		}
+	}
+	return PrefixRuleMatched, false
+}
+
+// Безопасное получение ID сообщества из контекста события: [events.GroupIDFromContext] паникует, если значения нет.
+func groupIDFromContext(ctx context.Context) (groupID int) {
+	if ctx == nil {
+		return 0
+	}
+	defer func() {
+		if recover() != nil {
+			groupID = 0
+		}
+	}()
+	return events.GroupIDFromContext(ctx)
+}
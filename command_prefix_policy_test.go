@@ -0,0 +1,111 @@
+package vkc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SevereCloud/vksdk/v3/events"
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+func TestPeerKinds(t *testing.T) {
+	tests := []struct {
+		peerID int
+		chat   bool
+		user   bool
+		group  bool
+	}{
+		{peerID: 1, user: true},
+		{peerID: 2000000000, user: true},
+		{peerID: 2000000001, chat: true},
+		{peerID: -123, group: true},
+	}
+
+	for _, tt := range tests {
+		if IsChatPeer(tt.peerID) != tt.chat || IsUserPeer(tt.peerID) != tt.user || IsGroupPeer(tt.peerID) != tt.group {
+			t.Errorf("peer %d: chat=%v user=%v group=%v, want %v %v %v", tt.peerID,
+				IsChatPeer(tt.peerID), IsUserPeer(tt.peerID), IsGroupPeer(tt.peerID), tt.chat, tt.user, tt.group)
+		}
+	}
+}
+
+func TestPrefixPolicy(t *testing.T) {
+	var rule PrefixRule
+	commands := Commands[any]{
+		Prefix: PrefixText("!"),
+		PrefixPolicy: PrefixPolicy{
+			OptionalInDirect: true,
+			ReplyToBot:       true,
+			GroupID:          10,
+		},
+		Handlers: []*CommandHandler[any]{
+			{
+				Pattern:  Text("help"),
+				Executor: func(ctx CommandContext[any]) error { rule = ctx.PrefixRule; return nil },
+			},
+		},
+	}
+
+	replyTo := func(fromID int) events.MessageNewObject {
+		msg := newTestMessage(2000000001, 1, "help")
+		msg.Message.ReplyMessage = &object.MessagesMessage{FromID: fromID}
+		return msg
+	}
+
+	tests := []struct {
+		name         string
+		msg          events.MessageNewObject
+		expectedErr  error
+		expectedRule PrefixRule
+	}{
+		{
+			name:         "prefix in chat",
+			msg:          newTestMessage(2000000001, 1, "!help"),
+			expectedRule: PrefixRuleMatched,
+		},
+		{
+			name:        "no prefix in chat",
+			msg:         newTestMessage(2000000001, 1, "help"),
+			expectedErr: ErrNoPrefix,
+		},
+		{
+			name:         "no prefix in direct messages",
+			msg:          newTestMessage(1, 1, "help"),
+			expectedRule: PrefixRuleDirect,
+		},
+		{
+			name:         "prefix in direct messages",
+			msg:          newTestMessage(1, 1, "!help"),
+			expectedRule: PrefixRuleMatched,
+		},
+		{
+			name:        "unknown command in direct messages",
+			msg:         newTestMessage(1, 1, "привет"),
+			expectedErr: ErrCommandNotFound,
+		},
+		{
+			name:         "reply to bot",
+			msg:          replyTo(-10),
+			expectedRule: PrefixRuleReplyToBot,
+		},
+		{
+			name:        "reply to user",
+			msg:         replyTo(2),
+			expectedErr: ErrNoPrefix,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule = -1
+			err := commands.ProcessCommands(context.Background(), nil, tt.msg)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("ProcessCommands() error = %v, want %v", err, tt.expectedErr)
+			}
+			if tt.expectedErr == nil && rule != tt.expectedRule {
+				t.Errorf("PrefixRule = %v, want %v", rule, tt.expectedRule)
+			}
+		})
+	}
+}
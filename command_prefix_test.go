@@ -1,6 +1,7 @@
 package vkc
 
 import (
+	"errors"
 	"regexp"
 	"testing"
 )
@@ -264,3 +265,144 @@ func TestPrefixFunc(t *testing.T) {
 		})
 	}
 }
+
+func TestPrefixRegexWithoutGroup(t *testing.T) {
+	matcher := PrefixRegex(regexp.MustCompile(`^!`))
+	matched, remaining := matcher("!help me")
+	if !matched || remaining != "help me" {
+		t.Errorf("PrefixRegex(`^!`)(%q) = %v, %q, want true, %q", "!help me", matched, remaining, "help me")
+	}
+}
+
+func TestCompilePrefixRegex(t *testing.T) {
+	tests := []struct {
+		name        string
+		expr        string
+		expectedErr error
+		wantErr     bool
+	}{
+		{
+			name: "valid",
+			expr: `^!(.*)$`,
+		},
+		{
+			name:        "no capture group",
+			expr:        `^!`,
+			expectedErr: ErrRegexNoCaptureGroup,
+			wantErr:     true,
+		},
+		{
+			name:    "invalid syntax",
+			expr:    `^!(`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher, err := CompilePrefixRegex(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CompilePrefixRegex(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+				t.Errorf("CompilePrefixRegex(%q) error = %v, want %v", tt.expr, err, tt.expectedErr)
+			}
+			if !tt.wantErr && matcher == nil {
+				t.Errorf("CompilePrefixRegex(%q) matcher = nil", tt.expr)
+			}
+		})
+	}
+
+	if _, err := NewPrefixRegex(nil); !errors.Is(err, ErrNilRegex) {
+		t.Errorf("NewPrefixRegex(nil) error = %v, want %v", err, ErrNilRegex)
+	}
+}
+
+func TestPrefixCombinators(t *testing.T) {
+	tests := []struct {
+		name           string
+		matcher        PrefixMatcher
+		input          string
+		expectedMatch  bool
+		expectedRemain string
+	}{
+		{
+			name:           "ignore case",
+			matcher:        PrefixIgnoreCase(PrefixText("бот")),
+			input:          "БОТ Help Me",
+			expectedMatch:  true,
+			expectedRemain: "Help Me",
+		},
+		{
+			name:          "ignore case no match",
+			matcher:       PrefixIgnoreCase(PrefixText("бот")),
+			input:         "робот help",
+			expectedMatch: false,
+		},
+		{
+			name:           "require space",
+			matcher:        PrefixRequireSpace(PrefixText("бот")),
+			input:          "бот help",
+			expectedMatch:  true,
+			expectedRemain: "help",
+		},
+		{
+			name:          "require space no space",
+			matcher:       PrefixRequireSpace(PrefixText("бот")),
+			input:         "ботhelp",
+			expectedMatch: false,
+		},
+		{
+			name:          "require space with punctuation",
+			matcher:       PrefixRequireSpace(PrefixText("!")),
+			input:         "!help",
+			expectedMatch: false,
+		},
+		{
+			name:           "require space prefix only",
+			matcher:        PrefixRequireSpace(PrefixText("бот")),
+			input:          "бот",
+			expectedMatch:  true,
+			expectedRemain: "",
+		},
+		{
+			name:           "word boundary",
+			matcher:        PrefixWordBoundary(PrefixText("бот")),
+			input:          "бот, help",
+			expectedMatch:  true,
+			expectedRemain: ", help",
+		},
+		{
+			name:          "word boundary inside word",
+			matcher:       PrefixWordBoundary(PrefixText("бот")),
+			input:         "ботик help",
+			expectedMatch: false,
+		},
+		{
+			name:           "word boundary punctuation prefix",
+			matcher:        PrefixWordBoundary(PrefixText("!")),
+			input:          "!help",
+			expectedMatch:  true,
+			expectedRemain: "help",
+		},
+		{
+			name:           "combined",
+			matcher:        PrefixAny(PrefixText("!"), PrefixIgnoreCase(PrefixWordBoundary(PrefixText("бот")))),
+			input:          "Бот help",
+			expectedMatch:  true,
+			expectedRemain: "help",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, remaining := tt.matcher(tt.input)
+			if matched != tt.expectedMatch {
+				t.Errorf("matcher(%q) matched = %v, want %v", tt.input, matched, tt.expectedMatch)
+			}
+			if remaining != tt.expectedRemain {
+				t.Errorf("matcher(%q) remaining = %q, want %q", tt.input, remaining, tt.expectedRemain)
+			}
+		})
+	}
+}
@@ -9,9 +9,11 @@ package vkc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"sync/atomic"
 
 	"github.com/SevereCloud/vksdk/v3/api"
 	"github.com/SevereCloud/vksdk/v3/events"
@@ -37,12 +39,48 @@ func logDeprecationWarning(feature string) {
 // Также в структуре есть поля для колбеков на события: OnMessage, OnEmptyPrefix, OnUnknownCommand, OnNoPermissions, OnCommandError. Их передача необязательна, однако, если указать эти обработчики, то они будут вызваны при соответствующих событиях.
 type Commands[DEPS any] struct {
 	Prefix PrefixMatcher
+	// Префикс с учетом контекста сообщения (например, [PeerPrefixes.Matcher]). Если задан, используется вместо Prefix.
+	ContextPrefix ContextPrefixMatcher
+	// Правила, по которым префикс может быть необязательным (например, в ЛС сообщества).
+	PrefixPolicy PrefixPolicy
 	// Структура для передачи зависимостей в обработчики команд. Если зависимости не требуются, можно указать any в дженерике.
 	//
 	// Deprecated: Начиная с v2 будет удалено. Рекомендуется перейти на [context.Context] (см. https://github.com/EgorBron/vkc/issues/2 для просмотра обсуждения).
 	Dependencies DEPS
 	Handlers     []*CommandHandler[DEPS]
 
+	// Отправитель сообщений для методов отправки [CommandContext]. Если не задан, используется [VKSender].
+	// Для соблюдения лимитов VK при большой нагрузке используйте [QueueSender].
+	Sender Sender
+	// Стратегия вычисления random_id исходящих сообщений. Если не задана, используется [CryptoRandomID].
+	// Чтобы повторная обработка того же события не дублировала ответы, укажите [EventRandomID].
+	RandomID RandomIDFunc
+	// Загрузчик вложений для [CommandContext.Upload]. Если не задан, используется VK из контекста.
+	Uploader Uploader
+	// Кеш загруженных вложений. Если не задан, файлы загружаются при каждой отправке.
+	AttachmentCache *AttachmentCache
+
+	// Хранилище состояния (сессий, настроек бесед и т.д.). Доступно в обработчиках через [CommandContext.Session] и соседние методы.
+	Store Store
+
+	// Зарегистрированные диалоги (см. [Dialog]). Запускаются из обработчиков методом [CommandContext.StartDialog].
+	Dialogs []*Dialog[DEPS]
+	// Хранилище состояний диалогов. Если не задано, диалоги недоступны. Для простых случаев подходит [NewMemoryDialogStore], для хранения вместе с остальными данными — [NewStoreDialogStore].
+	DialogStore DialogStore
+	// Реестр ожидающих ответа обработчиков. Если не задан, [CommandContext.Await] недоступен.
+	Awaiter *Awaiter
+	// Переводы сообщений и определение языка пользователя (см. [CommandContext.T]). Если не задано, используется русский язык.
+	I18n *I18n
+	// Источник времени для сроков диалогов и [CommandContext.Now]. Если не задан, используются системные часы.
+	Clock Clock
+	// Запись входящих событий для последующего воспроизведения (см. [Recorder] и [Replay]).
+	Recorder *Recorder
+	// Отсеивание повторно доставленных событий. Если не задано, каждое событие обрабатывается.
+	Deduplicator *Deduplicator
+	// Обрабатывать измененные сообщения (событие message_edit) при подключении через [Commands.AttachToLongPoll] или [Dispatcher].
+	// Что делать с измененной командой, определяет [CommandHandler.OnEdit].
+	HandleEdits bool
+
 	// Deprecated: Начиная с v2 будет удалено. Рекомендуется переход на вызов [ProcessCommands].
 	OnMessage *func(vk *api.VK, obj events.MessageNewObject)
 	// Deprecated: Начиная с v2 будет удалено. Рекомендуется переход на вызов [ProcessCommands].
@@ -69,6 +107,12 @@ type Commands[DEPS any] struct {
 //
 // и так далее.
 func FindCommand[DEPS any](rawCmd string, commands []*CommandHandler[DEPS]) (*CommandHandler[DEPS], string) {
+	return FindCommandLocale(rawCmd, "", "", commands)
+}
+
+// Поиск команды с учетом языка: кроме шаблона [CommandHandler.Pattern] проверяются названия команды [CommandHandler.Names] для языка locale,
+// а если для него у команды названий нет — для языка по умолчанию defaultLocale. В остальном работает так же, как [FindCommand].
+func FindCommandLocale[DEPS any](rawCmd, locale, defaultLocale string, commands []*CommandHandler[DEPS]) (*CommandHandler[DEPS], string) {
 	for _, handler := range commands {
 		if handler == nil {
 			continue
@@ -79,7 +123,7 @@ func FindCommand[DEPS any](rawCmd string, commands []*CommandHandler[DEPS]) (*Co
 		for i := len(words); i > 0; i-- {
 			candidate := strings.Join(words[:i], " ")
 
-			if handler.Pattern(candidate) {
+			if handler.matches(candidate, locale, defaultLocale) {
 				return handler, strings.Join(words[i:], " ")
 			}
 		}
@@ -94,44 +138,104 @@ func FindCommand[DEPS any](rawCmd string, commands []*CommandHandler[DEPS]) (*Co
 //
 // Процесс обработки команды включает следующие шаги:
 //
+//  0. Если задан [Commands.Recorder], событие записывается для последующего воспроизведения.
+//     Если задан [Commands.Deduplicator] и событие уже обрабатывалось, оно не передается в ожидания и диалоги,
+//     а на шаге 5 возвращается [ErrDuplicateEvent] (кроме обработчиков с [CommandHandler.AllowDuplicates]).
+//     Если обработчик ожидает ответа от автора сообщения в этой беседе (см. [CommandContext.Await]) и сообщение подходит под его условие, оно передается этому обработчику, и обработка на этом завершается.
+//     Иначе, если у автора сообщения есть активный диалог в этой беседе (см. [Dialog]), сообщение передается в текущий шаг диалога, и обработка на этом завершается.
 //  1. Проверка наличия текста в сообщении. Если текст отсутствует, возвращается ошибка [ErrEmptyMessage].
 //  2. (устарело) Вызов колбека [Commands.OnMessage] в горутине, если он указан, даже если в сообщении нет команды.
-//  3. Проверка наличия префикса в начале текста с помощью функции [Commands.Prefix]. Если префикс не найден, возвращается ошибка [ErrNoPrefix].
+//  3. Проверка наличия префикса в начале текста с помощью функции [Commands.ContextPrefix] или, если она не задана, [Commands.Prefix]. Если префикс не найден и [Commands.PrefixPolicy] не разрешает обойтись без него, возвращается ошибка [ErrNoPrefix].
 //  4. Если после удаления префикса не остается текста, вызывается колбек [Commands.OnEmptyPrefix] и возвращается ошибка [ErrEmptyPrefix].
-//  5. Поиск команды среди зарегистрированных обработчиков с помощью функции [FindCommand]. Если команда не найдена, вызывается колбек [Commands.OnUnknownCommand] и возвращается ошибка [ErrCommandNotFound].
+//  5. Поиск команды среди зарегистрированных обработчиков с помощью функции [FindCommandLocale] на языке автора сообщения. Если команда не найдена, вызывается колбек [Commands.OnUnknownCommand] и возвращается ошибка [ErrCommandNotFound].
 //  6. Проверка прав доступа к команде с помощью метода [Commands.IsAccessAvailable] обработчика команды. Если доступ запрещен, вызывается колбек [Commands.OnNoPermissions] и возвращается ошибка [ErrNoPermissions].
-//  7. Выполнение обработчика команды. Если во время выполнения возникает паника, она перехватывается и логируется с помощью функции [Stacktrace]. Если сам обработчик возвращает ошибку, вызывается колбек [Commands.OnCommandError] с этой ошибкой, и она же возвращается из метода.
+//  7. Выполнение обработчика команды (с отображением статуса [CommandHandler.Activity], если он задан). Если во время выполнения возникает паника, она перехватывается и логируется с помощью функции [Stacktrace]. Если сам обработчик возвращает ошибку, вызывается колбек [Commands.OnCommandError] с этой ошибкой, и она же возвращается из метода.
 //
 // Все колбеки на события имеют несколько особенностей:
 //   - они вызываются только если были установлены при создании структуры;
 //   - они выполняются в отдельных горутинах;
 //   - все они устарели и будут удалены в v2. Рекомендуется вместо этого обрабатывать ошибки метода ProcessCommands напрямую.
+//
+// Измененные сообщения обрабатываются методом [Commands.ProcessEdit].
 func (commands Commands[any]) ProcessCommands(ctx context.Context, vk *api.VK, msg events.MessageNewObject) error {
-	text := strings.TrimSpace(msg.Message.Text)
-	if text == "" {
-		return ErrEmptyMessage
-	}
+	return commands.process(ctx, vk, msg, false)
+}
 
-	if commands.OnMessage != nil {
-		logDeprecationWarning("OnMessage")
-		go (*commands.OnMessage)(vk, msg)
+func (commands Commands[any]) process(ctx context.Context, vk *api.VK, msg events.MessageNewObject, edited bool) error {
+	handled, duplicate, err := commands.intercept(ctx, msg, edited)
+	if handled || err != nil {
+		return err
 	}
+	return commands.handle(ctx, vk, msg, edited, duplicate)
+}
 
-	if commands.Prefix == nil {
-		return ErrNoPrefix
+// Шаг 0 без диалогов: запись события, проверка повтора и передача сообщения ожидающему обработчику.
+// handled = true, если сообщение передано в [CommandContext.Await]. Выделен, чтобы [Dispatcher] мог передавать ответы
+// ожидающим обработчикам в обход очереди беседы, которую занимает сам ожидающий обработчик.
+func (commands Commands[any]) intercept(ctx context.Context, msg events.MessageNewObject, edited bool) (handled, duplicate bool, err error) {
+	if commands.Recorder != nil {
+		commands.Recorder.record(ctx, msg, edited)
 	}
 
-	matched, rawCmd := commands.Prefix(text)
-	if !matched {
-		return ErrNoPrefix
+	if commands.Deduplicator != nil {
+		if duplicate, err = commands.Deduplicator.check(ctx, msg, edited, commands.Clock.now()); err != nil {
+			return false, false, err
+		}
+	}
+	// повторное событие не передается в ожидания и диалоги: ответ на них уже был получен;
+	// измененное сообщение тоже не считается ответом
+	if !duplicate && !edited && commands.Awaiter != nil && commands.Awaiter.deliver(msg.Message) {
+		return true, duplicate, nil
 	}
+	return false, duplicate, nil
+}
 
+// Шаги 0 (диалоги) – 7 для сообщения, прошедшего intercept.
+func (commands Commands[any]) handle(ctx context.Context, vk *api.VK, msg events.MessageNewObject, edited, duplicate bool) error {
 	cmdCtx := CommandContext[any]{
+		Context:    ctx,
 		VK:         vk,
 		Message:    msg.Message,
 		Arguments:  []string{},
 		RawEvent:   msg,
 		Dependency: commands.Dependencies,
+		Edited:     edited,
+		commands:   &commands,
+		sendSeq:    new(atomic.Int64),
+	}
+
+	if !duplicate && !edited {
+		if handled, err := commands.processDialog(cmdCtx); handled || err != nil {
+			return err
+		}
+	}
+
+	text := strings.TrimSpace(msg.Message.Text)
+	if text == "" {
+		return ErrEmptyMessage
+	}
+
+	if commands.OnMessage != nil {
+		logDeprecationWarning("OnMessage")
+		go (*commands.OnMessage)(vk, msg)
+	}
+
+	prefix := commands.ContextPrefix
+	if prefix == nil && commands.Prefix != nil {
+		prefix = commands.Prefix.WithContext()
+	}
+
+	matched, rawCmd := false, ""
+	if prefix != nil {
+		matched, rawCmd = prefix(PrefixContext{PeerID: msg.Message.PeerID, FromID: msg.Message.FromID, Message: msg.Message}, text)
+	}
+	if !matched {
+		rule, ok := commands.PrefixPolicy.allows(ctx, msg.Message)
+		if !ok {
+			return ErrNoPrefix
+		}
+		cmdCtx.PrefixRule = rule
+		rawCmd = text
 	}
 
 	if rawCmd == "" {
@@ -142,7 +246,13 @@ func (commands Commands[any]) ProcessCommands(ctx context.Context, vk *api.VK, m
 		return ErrEmptyPrefix
 	}
 
-	handler, remaining := FindCommand(rawCmd, commands.Handlers)
+	handler, remaining := FindCommandLocale(rawCmd, cmdCtx.Locale(), commands.I18n.defaultLocale(), commands.Handlers)
+	if duplicate && (handler == nil || !handler.AllowDuplicates) {
+		return ErrDuplicateEvent
+	}
+	if edited && (handler == nil || handler.OnEdit == EditIgnore) {
+		return ErrEditIgnored
+	}
 	if handler == nil {
 		if commands.OnUnknownCommand != nil {
 			logDeprecationWarning("OnUnknownCommand")
@@ -167,7 +277,26 @@ func (commands Commands[any]) ProcessCommands(ctx context.Context, vk *api.VK, m
 		}
 	}()
 
+	if handler.Activity != "" {
+		stop := cmdCtx.StartActivity(handler.Activity)
+		defer stop()
+	}
+
+	var saveReplies func() error
+	if handler.OnEdit == EditRerunAndEditReply {
+		var err error
+		if saveReplies, err = cmdCtx.trackReplies(); err != nil {
+			return err
+		}
+	}
+
 	err := handler.Executor(cmdCtx)
+	if saveReplies != nil {
+		// без сохраненных ответов следующее изменение отправит новые сообщения вместо изменения старых
+		if saveErr := saveReplies(); saveErr != nil {
+			err = errors.Join(err, saveErr)
+		}
+	}
 	if err != nil {
 		if commands.OnCommandError != nil {
 			logDeprecationWarning("OnCommandError")
@@ -180,6 +309,8 @@ func (commands Commands[any]) ProcessCommands(ctx context.Context, vk *api.VK, m
 
 // Подключение обработчика команд к LongPoll VK SDK.
 //
+// Сообщения обрабатываются прямо в колбеке Long Poll. Для параллельной обработки с сохранением порядка внутри беседы используйте [Dispatcher].
+//
 // Deprecated: Будет удалено в v2. Рекомендуется вызывать [Commands.ProcessCommands] напрямую из обработчика сообщений, вместо использования этого метода.
 func (commands Commands[any]) AttachToLongPoll(vk *api.VK, lp *longpoll.LongPoll) error {
 	if lp == nil {
@@ -188,6 +319,11 @@ func (commands Commands[any]) AttachToLongPoll(vk *api.VK, lp *longpoll.LongPoll
 	lp.MessageNew(func(ctx context.Context, msg events.MessageNewObject) {
 		commands.ProcessCommands(ctx, vk, msg)
 	})
+	if commands.HandleEdits {
+		lp.MessageEdit(func(ctx context.Context, msg events.MessageEditObject) {
+			commands.ProcessEdit(ctx, vk, msg)
+		})
+	}
 
 	return nil
 }
@@ -0,0 +1,155 @@
+package vkc
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SevereCloud/vksdk/v3/events"
+)
+
+// Время, в течение которого событие считается повторным, если в [Deduplicator] не задано другое.
+const DefaultDedupTTL = 10 * time.Minute
+
+// Максимальное количество ключей в памяти [Deduplicator], если не задано другое.
+const DefaultDedupMaxEntries = 10000
+
+type dedupEntry struct {
+	key       string
+	expiresAt time.Time
+}
+
+// Отсеивание повторно доставленных событий. Задается в поле [Commands.Deduplicator].
+//
+// Long Poll после переподключения и Callback API при повторах могут прислать одно и то же событие message_new дважды.
+// Событие считается повторным, если уже встречался его event_id или пара (peer_id, conversation_message_id).
+// Для повторных событий [Commands.ProcessCommands] возвращает [ErrDuplicateEvent], не выполняя команду,
+// если только у обработчика не включен [CommandHandler.AllowDuplicates].
+//
+// Событие отмечается как обработанное до выполнения команды, поэтому события с ошибкой обработки не повторяются:
+// если обработчик вернул ошибку (например, не удалось отправить ответ), повторная доставка того же события Callback API
+// тоже будет отброшена с [ErrDuplicateEvent]. Команды, которые нужно выполнять при повторной доставке, отмечайте
+// [CommandHandler.AllowDuplicates] и делайте идемпотентными. Отметка снимается только с событий, которые [Dispatcher]
+// отбросил, не начав обрабатывать (переполнение очереди, остановка).
+//
+// По умолчанию ключи хранятся в памяти процесса (не больше MaxEntries, старые вытесняются). Если задано Store,
+// ключи хранятся в нем (пространство имен "dedup"), что позволяет отсеивать повторы между перезапусками и несколькими экземплярами бота.
+//
+//	commands.Deduplicator = &Deduplicator{}
+type Deduplicator struct {
+	// Хранилище ключей. Если не задано, используется кэш в памяти.
+	Store Store
+	// Время хранения ключа. По умолчанию [DefaultDedupTTL].
+	TTL time.Duration
+	// Размер кэша в памяти. По умолчанию [DefaultDedupMaxEntries].
+	MaxEntries int
+
+	mu      sync.Mutex
+	seen    map[string]time.Time
+	entries []dedupEntry
+}
+
+func (dedup *Deduplicator) ttl() time.Duration {
+	if dedup.TTL <= 0 {
+		return DefaultDedupTTL
+	}
+	return dedup.TTL
+}
+
+// Ключи события: event_id (если известен) и пара (peer_id, conversation_message_id).
+// Для изменений сообщения к паре добавляется время изменения, чтобы изменение не считалось повтором исходного сообщения.
+func dedupKeys(ctx context.Context, msg events.MessageNewObject, edited bool) []string {
+	var keys []string
+	if eventID := eventIDFromContext(ctx); eventID != "" {
+		keys = append(keys, "event:"+eventID)
+	}
+	if cmid := msg.Message.ConversationMessageID; cmid != 0 {
+		key := "message:" + strconv.Itoa(msg.Message.PeerID) + ":" + strconv.Itoa(cmid)
+		if edited {
+			key = "edit:" + strconv.Itoa(msg.Message.PeerID) + ":" + strconv.Itoa(cmid) + ":" + strconv.Itoa(msg.Message.UpdateTime)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Отметка события message_new как обработанного. Возвращает true, если событие уже встречалось.
+func (dedup *Deduplicator) Check(ctx context.Context, msg events.MessageNewObject, now time.Time) (duplicate bool, err error) {
+	return dedup.check(ctx, msg, false, now)
+}
+
+func (dedup *Deduplicator) check(ctx context.Context, msg events.MessageNewObject, edited bool, now time.Time) (duplicate bool, err error) {
+	for _, key := range dedupKeys(ctx, msg, edited) {
+		var seen bool
+		if dedup.Store != nil {
+			seen, err = dedup.checkStore(key)
+			if err != nil {
+				return duplicate, err
+			}
+		} else {
+			seen = dedup.checkMemory(key, now)
+		}
+		duplicate = duplicate || seen
+	}
+	return duplicate, nil
+}
+
+// Снятие отметок события, которое не было обработано (например, отброшено [Dispatcher] при переполнении очереди).
+func (dedup *Deduplicator) forget(ctx context.Context, msg events.MessageNewObject, edited bool) error {
+	keys := dedupKeys(ctx, msg, edited)
+	if dedup.Store != nil {
+		store := Namespace(dedup.Store, "dedup")
+		for _, key := range keys {
+			if err := store.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	dedup.mu.Lock()
+	defer dedup.mu.Unlock()
+	for _, key := range keys {
+		// запись в entries остается и удаляется при вытеснении: оно проверяет, что ключ не добавлен заново
+		delete(dedup.seen, key)
+	}
+	return nil
+}
+
+func (dedup *Deduplicator) checkStore(key string) (bool, error) {
+	seen := false
+	err := Namespace(dedup.Store, "dedup").Update(key, dedup.ttl(), func(value []byte, ok bool) ([]byte, error) {
+		seen = ok
+		return []byte{1}, nil
+	})
+	return seen, err
+}
+
+func (dedup *Deduplicator) checkMemory(key string, now time.Time) bool {
+	dedup.mu.Lock()
+	defer dedup.mu.Unlock()
+	if dedup.seen == nil {
+		dedup.seen = make(map[string]time.Time)
+	}
+
+	// ключи добавляются с одинаковым TTL, поэтому самые старые всегда в начале
+	maxEntries := dedup.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = DefaultDedupMaxEntries
+	}
+	for len(dedup.entries) > 0 && (len(dedup.entries) >= maxEntries || !now.Before(dedup.entries[0].expiresAt)) {
+		oldest := dedup.entries[0]
+		dedup.entries = dedup.entries[1:]
+		if dedup.seen[oldest.key] == oldest.expiresAt {
+			delete(dedup.seen, oldest.key)
+		}
+	}
+
+	if expiresAt, ok := dedup.seen[key]; ok && now.Before(expiresAt) {
+		return true
+	}
+	expiresAt := now.Add(dedup.ttl())
+	dedup.seen[key] = expiresAt
+	dedup.entries = append(dedup.entries, dedupEntry{key: key, expiresAt: expiresAt})
+	return false
+}
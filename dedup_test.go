@@ -0,0 +1,113 @@
+package vkc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SevereCloud/vksdk/v3/events"
+)
+
+func newTestMessageCMID(peerID, fromID, cmid int, text string) events.MessageNewObject {
+	msg := newTestMessage(peerID, fromID, text)
+	msg.Message.ConversationMessageID = cmid
+	return msg
+}
+
+func TestDeduplication(t *testing.T) {
+	for _, store := range []Store{nil, NewMemoryStore()} {
+		calls := map[string]int{}
+		commands := Commands[any]{
+			Prefix:       PrefixText("!"),
+			Deduplicator: &Deduplicator{Store: store},
+			Handlers: []*CommandHandler[any]{
+				{
+					Pattern:  Text("ban"),
+					Executor: func(ctx CommandContext[any]) error { calls["ban"]++; return nil },
+				},
+				{
+					Pattern:         Text("ping"),
+					AllowDuplicates: true,
+					Executor:        func(ctx CommandContext[any]) error { calls["ping"]++; return nil },
+				},
+			},
+		}
+		ctx := context.Background()
+
+		if err := commands.ProcessCommands(ctx, nil, newTestMessageCMID(1, 1, 10, "!ban 5")); err != nil {
+			t.Fatalf("ProcessCommands() error = %v", err)
+		}
+		if err := commands.ProcessCommands(ctx, nil, newTestMessageCMID(1, 1, 10, "!ban 5")); !errors.Is(err, ErrDuplicateEvent) {
+			t.Errorf("duplicate ProcessCommands() error = %v, want %v", err, ErrDuplicateEvent)
+		}
+		// то же сообщение в другой беседе — не повтор
+		if err := commands.ProcessCommands(ctx, nil, newTestMessageCMID(2, 1, 10, "!ban 5")); err != nil {
+			t.Errorf("other peer ProcessCommands() error = %v", err)
+		}
+		for range 2 {
+			if err := commands.ProcessCommands(ctx, nil, newTestMessageCMID(1, 1, 11, "!ping")); err != nil {
+				t.Errorf("ping ProcessCommands() error = %v", err)
+			}
+		}
+
+		if calls["ban"] != 2 || calls["ping"] != 2 {
+			t.Errorf("store %T: calls = %v, want ban:2 ping:2", store, calls)
+		}
+	}
+}
+
+func TestDeduplicatorMemoryBounds(t *testing.T) {
+	dedup := &Deduplicator{TTL: time.Minute, MaxEntries: 2}
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	check := func(cmid int, now time.Time) bool {
+		duplicate, err := dedup.Check(ctx, newTestMessageCMID(1, 1, cmid, "!x"), now)
+		if err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+		return duplicate
+	}
+
+	if check(1, now) || !check(1, now) {
+		t.Error("second check of the same message is not a duplicate")
+	}
+	if check(1, now.Add(2*time.Minute)) {
+		t.Error("message after TTL is a duplicate")
+	}
+	check(2, now.Add(2*time.Minute))
+	check(3, now.Add(2*time.Minute))
+	if len(dedup.seen) > 2 {
+		t.Errorf("memory cache size = %d, want <= 2", len(dedup.seen))
+	}
+	if check(1, now.Add(2*time.Minute)) {
+		t.Error("evicted message is a duplicate")
+	}
+}
+
+func TestDeduplicationFailedHandler(t *testing.T) {
+	calls := 0
+	commands := Commands[any]{
+		Prefix:       PrefixText("!"),
+		Deduplicator: &Deduplicator{},
+		Handlers: []*CommandHandler[any]{{
+			Pattern: Text("send"),
+			Executor: func(ctx CommandContext[any]) error {
+				calls++
+				return ErrSenderClosed
+			},
+		}},
+	}
+	ctx := context.Background()
+
+	if err := commands.ProcessCommands(ctx, nil, newTestMessageCMID(1, 1, 10, "!send")); !errors.Is(err, ErrSenderClosed) {
+		t.Fatalf("ProcessCommands() error = %v, want %v", err, ErrSenderClosed)
+	}
+	// событие с ошибкой уже отмечено: повторная доставка не выполняет команду снова
+	if err := commands.ProcessCommands(ctx, nil, newTestMessageCMID(1, 1, 10, "!send")); !errors.Is(err, ErrDuplicateEvent) {
+		t.Errorf("redelivered ProcessCommands() error = %v, want %v", err, ErrDuplicateEvent)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
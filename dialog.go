@@ -0,0 +1,269 @@
+package vkc
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Слова, по которым диалог прерывается, если в [Dialog.CancelWords] ничего не указано.
+var DefaultCancelWords = []string{"отмена"}
+
+// Шаг диалога. Получает контекст с очередным сообщением пользователя и состояние диалога.
+//
+// Возвращает название следующего шага. Если вернуть пустую строку ([DialogEnd]), диалог завершается.
+// При ошибке диалог остается на текущем шаге, а ошибка возвращается из [Commands.ProcessCommands].
+type DialogStep[DEPS any] func(ctx CommandContext[DEPS], state *DialogState) (next string, err error)
+
+// Значение, которое нужно вернуть из шага, чтобы завершить диалог.
+const DialogEnd = ""
+
+// Диалог (многошаговый сценарий). Позволяет вести с пользователем беседу из нескольких сообщений,
+// например, для регистрации или опроса, не храня состояние в зависимостях вручную.
+//
+// Пример использования:
+//
+//	var Registration = Dialog[any]{
+//		Name:    "registration",
+//		Start:   "name",
+//		Timeout: 5 * time.Minute,
+//		Steps: map[string]DialogStep[any]{
+//			"name": func(ctx CommandContext[any], state *DialogState) (string, error) {
+//				state.Data["name"] = ctx.Message.Text
+//				return "age", ctx.SendText("Сколько вам лет?")
+//			},
+//			"age": func(ctx CommandContext[any], state *DialogState) (string, error) {
+//				return DialogEnd, ctx.SendText("%s, регистрация завершена!", state.Data["name"])
+//			},
+//		},
+//	}
+//	// позднее в обработчике команды:
+//	Executor: func(ctx CommandContext[any]) error {
+//		if err := ctx.StartDialog("registration"); err != nil {
+//			return err
+//		}
+//		return ctx.SendText("Как вас зовут?")
+//	},
+//
+// Сообщения пользователя, у которого есть активный диалог в беседе, передаются в текущий шаг до поиска префикса и команды.
+type Dialog[DEPS any] struct {
+	Name  string
+	Start string
+	Steps map[string]DialogStep[DEPS]
+	// Время ожидания ответа пользователя. Отсчитывается заново после каждого шага. Если равно нулю, диалог не истекает.
+	//
+	// Истекший диалог удаляется при получении следующего сообщения, а само сообщение обрабатывается как обычно.
+	Timeout time.Duration
+	// Слова для прерывания диалога (без учета регистра). Если не указаны, используются [DefaultCancelWords].
+	CancelWords []string
+	// Колбек на прерывание диалога пользователем. Вызывается синхронно, ошибка возвращается из [Commands.ProcessCommands].
+	OnCancel HandlerFunc[DEPS]
+}
+
+func (dialog *Dialog[DEPS]) isCancelWord(text string) bool {
+	words := dialog.CancelWords
+	if words == nil {
+		words = DefaultCancelWords
+	}
+	text = strings.TrimSpace(text)
+	return slices.ContainsFunc(words, func(word string) bool {
+		return strings.EqualFold(word, text)
+	})
+}
+
+// Состояние диалога с пользователем. Хранится в [DialogStore].
+//
+// Поле Data предназначено для данных, собранных на предыдущих шагах.
+type DialogState struct {
+	Dialog    string            `json:"dialog"`
+	Step      string            `json:"step"`
+	Data      map[string]string `json:"data"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	// Время, на которое диалог продлевается при каждом шаге ([Dialog.Timeout]). ExpiresAt считается по [Commands.Clock],
+	// поэтому хранилища используют для времени жизни записи это поле, а не разницу ExpiresAt с системными часами.
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+func (state *DialogState) expired(now time.Time) bool {
+	return !state.ExpiresAt.IsZero() && now.After(state.ExpiresAt)
+}
+
+// Ключ диалога: пользователь в конкретной беседе (или ЛС).
+type DialogKey struct {
+	PeerID int
+	UserID int
+}
+
+// Хранилище состояний диалогов. Реализация должна быть безопасна для конкурентного использования.
+//
+// Get возвращает nil без ошибки, если диалога нет.
+type DialogStore interface {
+	Get(key DialogKey) (*DialogState, error)
+	Set(key DialogKey, state *DialogState) error
+	Delete(key DialogKey) error
+}
+
+// Хранилище состояний диалогов в памяти процесса. Состояния теряются при перезапуске.
+type MemoryDialogStore struct {
+	mu     sync.Mutex
+	states map[DialogKey]DialogState
+}
+
+// Создание хранилища состояний диалогов в памяти.
+func NewMemoryDialogStore() *MemoryDialogStore {
+	return &MemoryDialogStore{states: make(map[DialogKey]DialogState)}
+}
+
+func (store *MemoryDialogStore) Get(key DialogKey) (*DialogState, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	state, ok := store.states[key]
+	if !ok {
+		return nil, nil
+	}
+	state.Data = cloneDialogData(state.Data)
+	return &state, nil
+}
+
+func (store *MemoryDialogStore) Set(key DialogKey, state *DialogState) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	copied := *state
+	copied.Data = cloneDialogData(state.Data)
+	store.states[key] = copied
+	return nil
+}
+
+func (store *MemoryDialogStore) Delete(key DialogKey) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	delete(store.states, key)
+	return nil
+}
+
+func cloneDialogData(data map[string]string) map[string]string {
+	cloned := make(map[string]string, len(data))
+	for k, v := range data {
+		cloned[k] = v
+	}
+	return cloned
+}
+
+func (ctx CommandContext[DEPS]) dialogKey() DialogKey {
+	return DialogKey{PeerID: ctx.Message.PeerID, UserID: ctx.Message.FromID}
+}
+
+func (commands Commands[DEPS]) findDialog(name string) *Dialog[DEPS] {
+	for _, dialog := range commands.Dialogs {
+		if dialog != nil && dialog.Name == name {
+			return dialog
+		}
+	}
+	return nil
+}
+
+// Передача сообщения в активный диалог пользователя. Возвращает false, если сообщение не относится к диалогу.
+func (commands Commands[DEPS]) processDialog(cmdCtx CommandContext[DEPS]) (bool, error) {
+	if commands.DialogStore == nil {
+		return false, nil
+	}
+
+	key := cmdCtx.dialogKey()
+	state, err := commands.DialogStore.Get(key)
+	if err != nil || state == nil {
+		return false, err
+	}
+
+	now := commands.Clock.now()
+	dialog := commands.findDialog(state.Dialog)
+	if dialog == nil || state.expired(now) {
+		return false, commands.DialogStore.Delete(key)
+	}
+
+	if dialog.isCancelWord(cmdCtx.Message.Text) {
+		if err := commands.DialogStore.Delete(key); err != nil {
+			return true, err
+		}
+		if dialog.OnCancel != nil {
+			return true, dialog.OnCancel(cmdCtx)
+		}
+		return true, nil
+	}
+
+	step, ok := dialog.Steps[state.Step]
+	if !ok {
+		if err := commands.DialogStore.Delete(key); err != nil {
+			return true, err
+		}
+		return true, ErrUnknownDialogStep
+	}
+	if state.Data == nil {
+		state.Data = make(map[string]string)
+	}
+
+	next, err := step(cmdCtx, state)
+	if err != nil {
+		return true, err
+	}
+
+	// шаг мог сам завершить или сменить диалог через контекст
+	current, err := commands.DialogStore.Get(key)
+	if err != nil {
+		return true, err
+	}
+	if current == nil || current.Dialog != state.Dialog || current.Step != state.Step {
+		return true, nil
+	}
+
+	if next == DialogEnd {
+		return true, commands.DialogStore.Delete(key)
+	}
+	if _, ok := dialog.Steps[next]; !ok {
+		if err := commands.DialogStore.Delete(key); err != nil {
+			return true, err
+		}
+		return true, ErrUnknownDialogStep
+	}
+	state.Step = next
+	if dialog.Timeout > 0 {
+		state.ExpiresAt = now.Add(dialog.Timeout)
+		state.Timeout = dialog.Timeout
+	}
+	return true, commands.DialogStore.Set(key, state)
+}
+
+// Запуск диалога для автора сообщения в текущей беседе. Предыдущий диалог пользователя в этой беседе, если он был, заменяется.
+//
+// Возвращает [ErrDialogsUnavailable], если в объекте команд не задано хранилище [Commands.DialogStore],
+// и [ErrUnknownDialog], если диалог с таким названием не зарегистрирован в [Commands.Dialogs].
+func (ctx CommandContext[DEPS]) StartDialog(name string) error {
+	if ctx.commands == nil || ctx.commands.DialogStore == nil {
+		return ErrDialogsUnavailable
+	}
+	dialog := ctx.commands.findDialog(name)
+	if dialog == nil {
+		return ErrUnknownDialog
+	}
+	if _, ok := dialog.Steps[dialog.Start]; !ok {
+		return ErrUnknownDialogStep
+	}
+	state := &DialogState{
+		Dialog: dialog.Name,
+		Step:   dialog.Start,
+		Data:   make(map[string]string),
+	}
+	if dialog.Timeout > 0 {
+		state.ExpiresAt = ctx.Now().Add(dialog.Timeout)
+		state.Timeout = dialog.Timeout
+	}
+	return ctx.commands.DialogStore.Set(ctx.dialogKey(), state)
+}
+
+// Завершение активного диалога автора сообщения в текущей беседе. Если диалога нет, ничего не делает.
+func (ctx CommandContext[DEPS]) EndDialog() error {
+	if ctx.commands == nil || ctx.commands.DialogStore == nil {
+		return ErrDialogsUnavailable
+	}
+	return ctx.commands.DialogStore.Delete(ctx.dialogKey())
+}
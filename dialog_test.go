@@ -0,0 +1,134 @@
+package vkc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SevereCloud/vksdk/v3/events"
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+func newTestMessage(peerID, fromID int, text string) events.MessageNewObject {
+	return events.MessageNewObject{
+		Message: object.MessagesMessage{
+			PeerID: peerID,
+			FromID: fromID,
+			Text:   text,
+		},
+	}
+}
+
+func newTestDialogCommands(steps *[]string) Commands[any] {
+	survey := &Dialog[any]{
+		Name:  "survey",
+		Start: "name",
+		Steps: map[string]DialogStep[any]{
+			"name": func(ctx CommandContext[any], state *DialogState) (string, error) {
+				*steps = append(*steps, "name:"+ctx.Message.Text)
+				state.Data["name"] = ctx.Message.Text
+				return "age", nil
+			},
+			"age": func(ctx CommandContext[any], state *DialogState) (string, error) {
+				*steps = append(*steps, "age:"+state.Data["name"]+":"+ctx.Message.Text)
+				return DialogEnd, nil
+			},
+		},
+		OnCancel: func(ctx CommandContext[any]) error {
+			*steps = append(*steps, "cancel")
+			return nil
+		},
+	}
+
+	return Commands[any]{
+		Prefix: PrefixText("!"),
+		Handlers: []*CommandHandler[any]{
+			{
+				Pattern: Text("survey"),
+				Executor: func(ctx CommandContext[any]) error {
+					*steps = append(*steps, "start")
+					return ctx.StartDialog("survey")
+				},
+			},
+		},
+		Dialogs:     []*Dialog[any]{survey},
+		DialogStore: NewMemoryDialogStore(),
+	}
+}
+
+func TestDialogSteps(t *testing.T) {
+	var steps []string
+	commands := newTestDialogCommands(&steps)
+	ctx := context.Background()
+
+	inputs := []string{"!survey", "Иван", "не команда от другого", "25", "после диалога"}
+	senders := []int{1, 1, 2, 1, 1}
+	for i, text := range inputs {
+		commands.ProcessCommands(ctx, nil, newTestMessage(2000000001, senders[i], text))
+	}
+
+	expected := []string{"start", "name:Иван", "age:Иван:25"}
+	if len(steps) != len(expected) {
+		t.Fatalf("steps = %v, want %v", steps, expected)
+	}
+	for i := range expected {
+		if steps[i] != expected[i] {
+			t.Errorf("steps[%d] = %q, want %q", i, steps[i], expected[i])
+		}
+	}
+}
+
+func TestDialogCancel(t *testing.T) {
+	var steps []string
+	commands := newTestDialogCommands(&steps)
+	ctx := context.Background()
+
+	commands.ProcessCommands(ctx, nil, newTestMessage(1, 1, "!survey"))
+	if err := commands.ProcessCommands(ctx, nil, newTestMessage(1, 1, "Отмена")); err != nil {
+		t.Fatalf("ProcessCommands() error = %v, want nil", err)
+	}
+	if err := commands.ProcessCommands(ctx, nil, newTestMessage(1, 1, "Иван")); !errors.Is(err, ErrNoPrefix) {
+		t.Errorf("ProcessCommands() after cancel error = %v, want %v", err, ErrNoPrefix)
+	}
+	if len(steps) != 2 || steps[1] != "cancel" {
+		t.Errorf("steps = %v, want [start cancel]", steps)
+	}
+}
+
+func TestDialogTimeout(t *testing.T) {
+	var steps []string
+	commands := newTestDialogCommands(&steps)
+	commands.Dialogs[0].Timeout = time.Minute
+	ctx := context.Background()
+
+	commands.ProcessCommands(ctx, nil, newTestMessage(1, 1, "!survey"))
+	key := DialogKey{PeerID: 1, UserID: 1}
+	state, _ := commands.DialogStore.Get(key)
+	if state == nil {
+		t.Fatal("dialog state was not saved")
+	}
+	state.ExpiresAt = time.Now().Add(-time.Second)
+	commands.DialogStore.Set(key, state)
+
+	if err := commands.ProcessCommands(ctx, nil, newTestMessage(1, 1, "Иван")); !errors.Is(err, ErrNoPrefix) {
+		t.Errorf("ProcessCommands() error = %v, want %v", err, ErrNoPrefix)
+	}
+	if state, _ := commands.DialogStore.Get(key); state != nil {
+		t.Errorf("expired dialog state = %+v, want nil", state)
+	}
+}
+
+func TestStartDialogErrors(t *testing.T) {
+	ctx := CommandContext[any]{}
+	if err := ctx.StartDialog("survey"); !errors.Is(err, ErrDialogsUnavailable) {
+		t.Errorf("StartDialog() without store error = %v, want %v", err, ErrDialogsUnavailable)
+	}
+
+	var steps []string
+	commands := newTestDialogCommands(&steps)
+	ctx.commands = &commands
+	if err := ctx.StartDialog("unknown"); !errors.Is(err, ErrUnknownDialog) {
+		t.Errorf("StartDialog(unknown) error = %v, want %v", err, ErrUnknownDialog)
+	}
+}
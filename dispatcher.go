@@ -0,0 +1,549 @@
+package vkc
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/SevereCloud/vksdk/v3/api"
+	"github.com/SevereCloud/vksdk/v3/events"
+	"github.com/SevereCloud/vksdk/v3/longpoll-bot"
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+// Ключ очереди диспетчера: сообщения с одинаковым ключом обрабатываются строго по очереди.
+type DispatchKeyFunc func(msg object.MessagesMessage) DialogKey
+
+// Очередь на беседу: команды одной беседы выполняются по порядку, разные беседы — параллельно.
+func DispatchByPeer(msg object.MessagesMessage) DialogKey {
+	return DialogKey{PeerID: msg.PeerID}
+}
+
+// Очередь на пользователя: команды одного пользователя выполняются по порядку во всех беседах.
+func DispatchByUser(msg object.MessagesMessage) DialogKey {
+	return DialogKey{UserID: msg.FromID}
+}
+
+// Очередь на пользователя в беседе: разные пользователи одной беседы обрабатываются параллельно.
+func DispatchByPeerUser(msg object.MessagesMessage) DialogKey {
+	return DialogKey{PeerID: msg.PeerID, UserID: msg.FromID}
+}
+
+// Поведение диспетчера при переполнении очереди.
+type OverflowPolicy int
+
+const (
+	// Ожидание свободного места: [Dispatcher.Dispatch] блокируется, замедляя получение событий (backpressure).
+	OverflowBlock OverflowPolicy = iota
+	// Отбрасывание нового сообщения: [Dispatcher.Dispatch] возвращает [ErrDispatchQueueFull].
+	OverflowDropNewest
+	// Отбрасывание самого старого ожидающего сообщения той же очереди в пользу нового.
+	OverflowDropOldest
+)
+
+// Статистика диспетчера.
+type DispatcherStats struct {
+	// Сообщений в очередях и в обработке.
+	Queued   int
+	InFlight int
+	// Всего обработано и отброшено сообщений.
+	Processed int64
+	Dropped   int64
+	// Всего ответов, переданных ожидающим обработчикам ([CommandContext.Await]) в обход очереди.
+	// Они не входят в Processed и не учитываются во времени ожидания в очереди.
+	Intercepted int64
+	// Время ожидания в очереди (от [Dispatcher.Dispatch] до начала обработки): среднее и максимальное.
+	AvgQueueLatency time.Duration
+	MaxQueueLatency time.Duration
+}
+
+type dispatchItem struct {
+	ctx       context.Context
+	msg       events.MessageNewObject
+	edit      bool
+	duplicate bool
+	queuedAt  time.Time
+}
+
+// Команда, выполняющаяся в диспетчере.
+type inFlightCommand struct {
+	msg     events.MessageNewObject
+	started time.Time
+	cancel  context.CancelFunc
+}
+
+// Команда, прерванная при остановке диспетчера.
+type AbortedCommand struct {
+	Message events.MessageNewObject
+	// Сколько команда выполнялась к моменту прерывания.
+	Running time.Duration
+}
+
+// Ошибка [Dispatcher.Shutdown], если не все команды успели завершиться. Оборачивает ошибку контекста
+// и ошибку закрытия отправителя, если она была.
+type ShutdownError struct {
+	Aborted []AbortedCommand
+	// Прерванные команды, которые не завершились и за [Dispatcher.AbortTimeout] после отмены их контекстов.
+	Running []AbortedCommand
+	Err     error
+	// Ошибка закрытия отправителя (например, не все сообщения [QueueSender] успели отправиться).
+	CloseErr error
+}
+
+func (err *ShutdownError) Error() string {
+	text := fmt.Sprintf("dispatcher shutdown: %d commands aborted", len(err.Aborted))
+	if len(err.Running) > 0 {
+		text += fmt.Sprintf(", %d still running", len(err.Running))
+	}
+	text += fmt.Sprintf(": %v", err.Err)
+	if err.CloseErr != nil {
+		text += fmt.Sprintf("; %v", err.CloseErr)
+	}
+	return text
+}
+
+func (err *ShutdownError) Unwrap() []error {
+	if err.CloseErr == nil {
+		return []error{err.Err}
+	}
+	return []error{err.Err, err.CloseErr}
+}
+
+type dispatchQueue struct {
+	items   []dispatchItem
+	running bool
+}
+
+// Диспетчер сообщений: обрабатывает их в ограниченном пуле горутин, сохраняя порядок внутри одной беседы (или пользователя, см. Key).
+//
+// В отличие от вызова [Commands.ProcessCommands] прямо из Long Poll, медленная команда не задерживает остальные беседы,
+// а в отличие от lp.Goroutine(true), количество одновременно выполняемых команд и размер очередей ограничены.
+//
+// Пример использования:
+//
+//	dispatcher := &Dispatcher[any]{
+//		Commands: commands,
+//		VK:       vk,
+//		Workers:  16,
+//		Overflow: OverflowDropOldest,
+//	}
+//	dispatcher.AttachToLongPoll(lp)
+type Dispatcher[DEPS any] struct {
+	Commands Commands[DEPS]
+	VK       *api.VK
+	// Количество горутин-обработчиков. По умолчанию 8.
+	Workers int
+	// Максимальное количество ожидающих сообщений в одной очереди. По умолчанию 64.
+	QueueSize int
+	// Максимальное количество ожидающих сообщений во всех очередях. По умолчанию не ограничено.
+	MaxQueued int
+	// Ключ очереди. По умолчанию [DispatchByPeer].
+	Key      DispatchKeyFunc
+	Overflow OverflowPolicy
+	// Вызывается для каждой ошибки [Commands.ProcessCommands] (в горутине обработчика).
+	OnError func(msg events.MessageNewObject, err error)
+	// Вызывается для каждого отброшенного сообщения, в том числе не начатого к моменту остановки.
+	OnDrop func(msg events.MessageNewObject)
+	// Время на завершение команд при остановке через отмену контекста [Dispatcher.Run]. По умолчанию 30 секунд.
+	ShutdownTimeout time.Duration
+	// Время ожидания выхода команд после отмены их контекстов в [Dispatcher.Shutdown]. По умолчанию 5 секунд.
+	AbortTimeout time.Duration
+
+	once   sync.Once
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queues map[DialogKey]*dispatchQueue
+	// очереди, готовые к обработке, в порядке поступления
+	ready    []DialogKey
+	queued   int
+	inFlight map[uint64]*inFlightCommand
+	nextID   uint64
+	stats    DispatcherStats
+	latency  time.Duration
+	closed   bool
+	// закрывается при вызове Shutdown
+	done chan struct{}
+	// отправитель закрывается один раз, повторные Shutdown его не трогают
+	closeMu      sync.Mutex
+	senderClosed bool
+}
+
+func (d *Dispatcher[DEPS]) init() {
+	d.once.Do(func() {
+		d.cond = sync.NewCond(&d.mu)
+		d.queues = make(map[DialogKey]*dispatchQueue)
+		d.inFlight = make(map[uint64]*inFlightCommand)
+		d.done = make(chan struct{})
+		workers := d.Workers
+		if workers <= 0 {
+			workers = 8
+		}
+		for range workers {
+			go d.work()
+		}
+	})
+}
+
+func (d *Dispatcher[DEPS]) queueSize() int {
+	if d.QueueSize <= 0 {
+		return 64
+	}
+	return d.QueueSize
+}
+
+func (d *Dispatcher[DEPS]) key(msg object.MessagesMessage) DialogKey {
+	if d.Key == nil {
+		return DispatchByPeer(msg)
+	}
+	return d.Key(msg)
+}
+
+func (d *Dispatcher[DEPS]) full(q *dispatchQueue) bool {
+	return len(q.items) >= d.queueSize() || (d.MaxQueued > 0 && d.queued >= d.MaxQueued)
+}
+
+// Постановка сообщения в очередь. Обработка начнется в одной из горутин пула.
+//
+// При переполнении очереди поведение зависит от Overflow: ожидание (до отмены ctx), отказ с [ErrDispatchQueueFull]
+// или отбрасывание самого старого сообщения очереди. Значения ctx (group_id, event_id) передаются в обработку, а его отмена — нет.
+//
+// Запись события ([Commands.Recorder]), проверка повтора ([Commands.Deduplicator]) и передача ответа ожидающему
+// обработчику ([CommandContext.Await]) выполняются сразу, до постановки в очередь: иначе ответ встал бы в очередь
+// за самим ожидающим обработчиком и не был бы получен. Если сообщение затем отбрасывается, его отметка
+// в [Commands.Deduplicator] снимается, и повторная доставка будет обработана.
+//
+// После вызова [Dispatcher.Shutdown] возвращает [ErrDispatcherClosed].
+func (d *Dispatcher[DEPS]) Dispatch(ctx context.Context, msg events.MessageNewObject) error {
+	return d.enqueue(ctx, msg, false)
+}
+
+// Постановка в очередь измененного сообщения (событие message_edit, см. [Commands.ProcessEdit]).
+// Изменение попадает в ту же очередь, что и новые сообщения беседы, поэтому порядок сохраняется.
+func (d *Dispatcher[DEPS]) DispatchEdit(ctx context.Context, msg events.MessageEditObject) error {
+	return d.enqueue(ctx, events.MessageNewObject{Message: object.MessagesMessage(msg)}, true)
+}
+
+func (d *Dispatcher[DEPS]) enqueue(ctx context.Context, msg events.MessageNewObject, edit bool) error {
+	d.init()
+	key := d.key(msg.Message)
+
+	d.mu.Lock()
+	closed := d.closed
+	d.mu.Unlock()
+	if closed {
+		return ErrDispatcherClosed
+	}
+	handled, duplicate, err := d.Commands.intercept(ctx, msg, edit)
+	if err != nil {
+		if d.OnError != nil {
+			d.OnError(msg, err)
+		}
+		return fmt.Errorf("dispatch: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if handled {
+		d.stats.Intercepted++
+		return nil
+	}
+	if d.closed {
+		return ErrDispatcherClosed
+	}
+	q := d.queues[key]
+	if q == nil {
+		q = &dispatchQueue{}
+		d.queues[key] = q
+	}
+
+	item := dispatchItem{
+		ctx:       context.WithoutCancel(ctx),
+		msg:       msg,
+		edit:      edit,
+		duplicate: duplicate,
+	}
+	for d.full(q) {
+		switch d.Overflow {
+		case OverflowDropNewest:
+			d.drop(item)
+			return ErrDispatchQueueFull
+		case OverflowDropOldest:
+			if len(q.items) == 0 {
+				d.drop(item)
+				return ErrDispatchQueueFull
+			}
+			d.drop(q.items[0])
+			q.items = q.items[1:]
+			d.queued--
+		default:
+			if err := d.wait(ctx); err != nil {
+				return fmt.Errorf("dispatch: %w", err)
+			}
+			if d.closed {
+				return ErrDispatcherClosed
+			}
+			// очередь могла быть удалена, пока мы ждали
+			if current := d.queues[key]; current != q {
+				if current == nil {
+					d.queues[key] = q
+				} else {
+					q = current
+				}
+			}
+		}
+	}
+
+	item.queuedAt = d.Commands.Clock.now()
+	q.items = append(q.items, item)
+	d.queued++
+	if !q.running {
+		q.running = true
+		d.ready = append(d.ready, key)
+		d.cond.Broadcast()
+	}
+	return nil
+}
+
+// Ожидание изменения очередей с учетом отмены ctx. Вызывается под блокировкой.
+func (d *Dispatcher[DEPS]) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.cond.Broadcast()
+	})
+	defer stop()
+	d.cond.Wait()
+	return ctx.Err()
+}
+
+// Отбрасывание сообщения. Вызывается под блокировкой.
+//
+// Отметка [Commands.Deduplicator], поставленная при постановке в очередь, снимается: сообщение не обработано,
+// поэтому его повторная доставка не должна считаться повтором. Если сообщение уже было повтором, отметки остаются.
+func (d *Dispatcher[DEPS]) drop(item dispatchItem) {
+	d.stats.Dropped++
+	if !item.duplicate && d.Commands.Deduplicator != nil {
+		if err := d.Commands.Deduplicator.forget(item.ctx, item.msg, item.edit); err != nil && d.OnError != nil {
+			go d.OnError(item.msg, err)
+		}
+	}
+	if d.OnDrop != nil {
+		go d.OnDrop(item.msg)
+	}
+}
+
+func (d *Dispatcher[DEPS]) work() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for {
+		for len(d.ready) == 0 && !d.closed {
+			d.cond.Wait()
+		}
+		if d.closed {
+			return
+		}
+		key := d.ready[0]
+		d.ready = d.ready[1:]
+		q := d.queues[key]
+		item := q.items[0]
+		q.items = q.items[1:]
+		d.queued--
+
+		now := d.Commands.Clock.now()
+		ctx, cancel := context.WithCancel(item.ctx)
+		id := d.nextID
+		d.nextID++
+		d.inFlight[id] = &inFlightCommand{msg: item.msg, started: now, cancel: cancel}
+
+		latency := now.Sub(item.queuedAt)
+		d.latency += latency
+		d.stats.MaxQueueLatency = max(d.stats.MaxQueueLatency, latency)
+		// освобождено место в очереди
+		d.cond.Broadcast()
+
+		d.mu.Unlock()
+		err := d.Commands.handle(ctx, d.VK, item.msg, item.edit, item.duplicate)
+		cancel()
+		if err != nil && d.OnError != nil {
+			d.OnError(item.msg, err)
+		}
+		d.mu.Lock()
+
+		delete(d.inFlight, id)
+		d.stats.Processed++
+		d.cond.Broadcast()
+		if d.closed {
+			// очереди уже очищены в Shutdown
+			continue
+		}
+		if len(q.items) > 0 {
+			// в конец списка готовых, чтобы активная беседа не занимала горутину в ущерб остальным
+			d.ready = append(d.ready, key)
+			d.cond.Broadcast()
+		} else {
+			q.running = false
+			delete(d.queues, key)
+		}
+	}
+}
+
+// Текущая статистика диспетчера.
+func (d *Dispatcher[DEPS]) Stats() DispatcherStats {
+	d.init()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stats := d.stats
+	stats.Queued = d.queued
+	stats.InFlight = len(d.inFlight)
+	if started := stats.Processed + int64(len(d.inFlight)); started > 0 {
+		stats.AvgQueueLatency = d.latency / time.Duration(started)
+	}
+	return stats
+}
+
+// Подключение диспетчера к Long Poll вместо [Commands.AttachToLongPoll].
+// Если включен [Commands.HandleEdits], подключается и обработка измененных сообщений.
+func (d *Dispatcher[DEPS]) AttachToLongPoll(lp *longpoll.LongPoll) error {
+	if lp == nil {
+		return fmt.Errorf("LongPoll was nil")
+	}
+	lp.MessageNew(func(ctx context.Context, msg events.MessageNewObject) {
+		d.Dispatch(ctx, msg)
+	})
+	if d.Commands.HandleEdits {
+		lp.MessageEdit(func(ctx context.Context, msg events.MessageEditObject) {
+			d.DispatchEdit(ctx, msg)
+		})
+	}
+	return nil
+}
+
+// Запуск диспетчера. Блокируется до отмены ctx или вызова [Dispatcher.Shutdown].
+//
+// При отмене ctx выполняется остановка с ожиданием команд не дольше ShutdownTimeout; в этом случае Run возвращает nil
+// или [*ShutdownError]. Если диспетчер остановлен вызовом Shutdown, Run возвращает [ErrDispatcherClosed].
+//
+// Пример использования:
+//
+//	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+//	defer stop()
+//	dispatcher.AttachToLongPoll(lp)
+//	go lp.RunWithContext(ctx)
+//	if err := dispatcher.Run(ctx); err != nil {
+//		log.Print(err)
+//	}
+func (d *Dispatcher[DEPS]) Run(ctx context.Context) error {
+	d.init()
+	select {
+	case <-d.done:
+		return ErrDispatcherClosed
+	case <-ctx.Done():
+	}
+
+	timeout := d.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+	return d.Shutdown(shutdownCtx)
+}
+
+// Остановка диспетчера: новые сообщения больше не принимаются ([ErrDispatcherClosed]), не начатые сообщения
+// отбрасываются (с вызовом OnDrop), а выполняющиеся команды дорабатывают до отмены ctx.
+// Затем, если отправитель [Commands.Sender] поддерживает закрытие (как [QueueSender]), ожидается отправка оставшихся сообщений.
+//
+// Если ctx отменен раньше, контексты выполняющихся команд отменяются, Shutdown ждет их выхода не дольше AbortTimeout,
+// закрывает отправитель с уже отмененным ctx (неотправленные сообщения завершаются ошибкой) и возвращает [*ShutdownError]
+// со списком прерванных команд и тех из них, что еще выполняются. Поэтому обработчики должны завершаться при отмене
+// [CommandContext.Context]; сообщения, отправленные ими после остановки, завершаются ошибкой [ErrSenderClosed].
+// Повторный вызов ожидает завершения тех же команд; отправитель закрывается только один раз.
+func (d *Dispatcher[DEPS]) Shutdown(ctx context.Context) error {
+	d.init()
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.done)
+		for _, q := range d.queues {
+			for _, item := range q.items {
+				d.drop(item)
+			}
+		}
+		d.queues = make(map[DialogKey]*dispatchQueue)
+		d.ready = nil
+		d.queued = 0
+		d.cond.Broadcast()
+	}
+
+	for len(d.inFlight) > 0 {
+		if err := d.wait(ctx); err != nil {
+			aborted := d.abort()
+			// ждем выхода прерванных команд, но не бесконечно: обработчик может не проверять отмену контекста
+			abortCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), d.abortTimeout())
+			for len(d.inFlight) > 0 {
+				if d.wait(abortCtx) != nil {
+					break
+				}
+			}
+			cancel()
+			shutdownErr := &ShutdownError{Aborted: aborted, Running: d.running(), Err: err}
+			d.mu.Unlock()
+			shutdownErr.CloseErr = d.closeSender(ctx)
+			return shutdownErr
+		}
+	}
+	d.mu.Unlock()
+	return d.closeSender(ctx)
+}
+
+// Закрытие отправителя, если он это поддерживает. После успешного закрытия повторные вызовы ничего не делают.
+func (d *Dispatcher[DEPS]) closeSender(ctx context.Context) error {
+	closer, ok := d.Commands.Sender.(interface{ Close(context.Context) error })
+	if !ok {
+		return nil
+	}
+	d.closeMu.Lock()
+	defer d.closeMu.Unlock()
+	if d.senderClosed {
+		return nil
+	}
+	if err := closer.Close(ctx); err != nil {
+		return fmt.Errorf("dispatcher shutdown: close sender: %w", err)
+	}
+	d.senderClosed = true
+	return nil
+}
+
+func (d *Dispatcher[DEPS]) abortTimeout() time.Duration {
+	if d.AbortTimeout <= 0 {
+		return 5 * time.Second
+	}
+	return d.AbortTimeout
+}
+
+// Отмена контекстов всех выполняющихся команд. Вызывается под блокировкой.
+func (d *Dispatcher[DEPS]) abort() []AbortedCommand {
+	for _, command := range d.inFlight {
+		command.cancel()
+	}
+	return d.running()
+}
+
+// Выполняющиеся команды, начиная с самой долгой. Вызывается под блокировкой.
+func (d *Dispatcher[DEPS]) running() []AbortedCommand {
+	now := d.Commands.Clock.now()
+	running := make([]AbortedCommand, 0, len(d.inFlight))
+	for _, command := range d.inFlight {
+		running = append(running, AbortedCommand{Message: command.msg, Running: now.Sub(command.started)})
+	}
+	slices.SortFunc(running, func(a, b AbortedCommand) int {
+		return cmp.Compare(b.Running, a.Running)
+	})
+	return running
+}
@@ -0,0 +1,387 @@
+package vkc
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SevereCloud/vksdk/v3/events"
+)
+
+// Ожидание условия с ограничением по времени.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition was not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDispatcherOrdering(t *testing.T) {
+	var mu sync.Mutex
+	order := make(map[int][]int)
+	var running, maxRunning atomic.Int32
+
+	d := &Dispatcher[any]{
+		Workers: 4,
+		Commands: Commands[any]{
+			Prefix: PrefixText("!"),
+			Handlers: []*CommandHandler[any]{{
+				Pattern: Text("n"),
+				Executor: func(ctx CommandContext[any]) error {
+					n := running.Add(1)
+					defer running.Add(-1)
+					for {
+						current := maxRunning.Load()
+						if n <= current || maxRunning.CompareAndSwap(current, n) {
+							break
+						}
+					}
+					time.Sleep(time.Millisecond)
+					i, _ := strconv.Atoi(ctx.Arguments[0])
+					mu.Lock()
+					order[ctx.Message.PeerID] = append(order[ctx.Message.PeerID], i)
+					mu.Unlock()
+					return nil
+				},
+			}},
+		},
+	}
+
+	const peers, perPeer = 10, 20
+	for i := range perPeer {
+		for peer := 1; peer <= peers; peer++ {
+			if err := d.Dispatch(context.Background(), newTestMessage(peer, peer, "!n "+strconv.Itoa(i))); err != nil {
+				t.Fatalf("Dispatch() error = %v", err)
+			}
+		}
+	}
+	waitFor(t, func() bool { return d.Stats().Processed == peers*perPeer })
+
+	for peer := 1; peer <= peers; peer++ {
+		for i, n := range order[peer] {
+			if n != i {
+				t.Fatalf("peer %d order = %v, want ascending", peer, order[peer])
+			}
+		}
+	}
+	if maxRunning.Load() > 4 {
+		t.Errorf("max concurrent executors = %d, want <= 4", maxRunning.Load())
+	}
+	if stats := d.Stats(); stats.Queued != 0 || stats.InFlight != 0 || stats.MaxQueueLatency <= 0 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestDispatcherAwait(t *testing.T) {
+	answers := make(chan string, 1)
+	d := &Dispatcher[any]{
+		Commands: Commands[any]{
+			Prefix:  PrefixText("!"),
+			Awaiter: &Awaiter{},
+			Handlers: []*CommandHandler[any]{{
+				Pattern: Text("ask"),
+				Executor: func(ctx CommandContext[any]) error {
+					answer, err := ctx.Await(time.Second, nil)
+					if err != nil {
+						return err
+					}
+					answers <- answer.Text
+					return nil
+				},
+			}},
+		},
+	}
+	defer d.Shutdown(context.Background())
+
+	if err := d.Dispatch(context.Background(), newTestMessage(1, 1, "!ask")); err != nil {
+		t.Fatalf("Dispatch() error = %v", err)
+	}
+	awaiter := d.Commands.Awaiter
+	waitFor(t, func() bool {
+		awaiter.mu.Lock()
+		defer awaiter.mu.Unlock()
+		return len(awaiter.pending) == 1
+	})
+	// ответ в ту же беседу не ждет в очереди за ожидающим обработчиком
+	if err := d.Dispatch(context.Background(), newTestMessage(1, 1, "да")); err != nil {
+		t.Fatalf("Dispatch() error = %v", err)
+	}
+	select {
+	case answer := <-answers:
+		if answer != "да" {
+			t.Errorf("Await() = %q, want %q", answer, "да")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Await() did not receive the answer")
+	}
+	waitFor(t, func() bool { return d.Stats().Processed == 1 })
+	if stats := d.Stats(); stats.Intercepted != 1 {
+		t.Errorf("Stats().Intercepted = %d, want 1", stats.Intercepted)
+	}
+}
+
+func TestDispatcherOverflow(t *testing.T) {
+	release := make(chan struct{})
+	var processed []string
+	var mu sync.Mutex
+	newDispatcher := func(policy OverflowPolicy) *Dispatcher[any] {
+		return &Dispatcher[any]{
+			Workers:   1,
+			QueueSize: 2,
+			Overflow:  policy,
+			Commands: Commands[any]{
+				Prefix:       PrefixText("!"),
+				Deduplicator: &Deduplicator{},
+				Handlers: []*CommandHandler[any]{{
+					Pattern: Text("wait"),
+					Executor: func(ctx CommandContext[any]) error {
+						<-release
+						mu.Lock()
+						processed = append(processed, ctx.Arguments[0])
+						mu.Unlock()
+						return nil
+					},
+				}},
+			},
+		}
+	}
+
+	tests := []struct {
+		policy   OverflowPolicy
+		expected string
+		// номер отброшенного сообщения
+		dropped int
+	}{
+		{OverflowDropNewest, "0,1,2,3", 3},
+		{OverflowDropOldest, "0,2,3,1", 1},
+	}
+	for _, tt := range tests {
+		processed = nil
+		release = make(chan struct{})
+		d := newDispatcher(tt.policy)
+		ctx := context.Background()
+
+		message := func(i int) events.MessageNewObject {
+			return newTestMessageCMID(1, 1, i+1, "!wait "+strconv.Itoa(i))
+		}
+		d.Dispatch(ctx, message(0))
+		waitFor(t, func() bool { return d.Stats().InFlight == 1 })
+		d.Dispatch(ctx, message(1))
+		d.Dispatch(ctx, message(2))
+		err := d.Dispatch(ctx, message(3))
+		if tt.policy == OverflowDropNewest && !errors.Is(err, ErrDispatchQueueFull) {
+			t.Errorf("policy %d: Dispatch() error = %v, want %v", tt.policy, err, ErrDispatchQueueFull)
+		}
+		if d.Stats().Dropped != 1 {
+			t.Errorf("policy %d: dropped = %d, want 1", tt.policy, d.Stats().Dropped)
+		}
+
+		close(release)
+		waitFor(t, func() bool { return d.Stats().Processed == 3 })
+		// отброшенное сообщение не отмечено как обработанное, поэтому его повторная доставка выполняется
+		if err := d.Dispatch(ctx, message(tt.dropped)); err != nil {
+			t.Errorf("policy %d: redelivered Dispatch() error = %v", tt.policy, err)
+		}
+		waitFor(t, func() bool { return d.Stats().Processed == 4 })
+		mu.Lock()
+		actual := strings.Join(processed, ",")
+		mu.Unlock()
+		if actual != tt.expected {
+			t.Errorf("policy %d: processed = %s, want %s", tt.policy, actual, tt.expected)
+		}
+	}
+}
+
+func TestDispatcherBlock(t *testing.T) {
+	release := make(chan struct{})
+	d := &Dispatcher[any]{
+		Workers:   1,
+		QueueSize: 1,
+		Commands: Commands[any]{
+			Prefix: PrefixText("!"),
+			Handlers: []*CommandHandler[any]{{
+				Pattern:  Text("wait"),
+				Executor: func(ctx CommandContext[any]) error { <-release; return nil },
+			}},
+		},
+	}
+	d.Dispatch(context.Background(), newTestMessage(1, 1, "!wait"))
+	waitFor(t, func() bool { return d.Stats().InFlight == 1 })
+	d.Dispatch(context.Background(), newTestMessage(1, 1, "!wait"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := d.Dispatch(ctx, newTestMessage(1, 1, "!wait")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Dispatch() on full queue error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- d.Dispatch(context.Background(), newTestMessage(1, 1, "!wait")) }()
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("blocked Dispatch() error = %v, want nil", err)
+	}
+	waitFor(t, func() bool { return d.Stats().Processed == 3 })
+}
+
+func TestDispatcherShutdown(t *testing.T) {
+	release := make(chan struct{})
+	var finished atomic.Int32
+	var dropped atomic.Int32
+	var exited atomic.Bool
+	d := &Dispatcher[any]{
+		Workers: 2,
+		OnDrop:  func(events.MessageNewObject) { dropped.Add(1) },
+		Commands: Commands[any]{
+			Prefix: PrefixText("!"),
+			Handlers: []*CommandHandler[any]{
+				{
+					Pattern: Text("slow"),
+					Executor: func(ctx CommandContext[any]) error {
+						<-release
+						finished.Add(1)
+						return nil
+					},
+				},
+				{
+					Pattern: Text("stuck"),
+					Executor: func(ctx CommandContext[any]) error {
+						<-ctx.Context.Done()
+						// прерванная команда завершается не мгновенно
+						time.Sleep(10 * time.Millisecond)
+						exited.Store(true)
+						return ctx.Context.Err()
+					},
+				},
+			},
+		},
+	}
+	ctx := context.Background()
+
+	d.Dispatch(ctx, newTestMessage(1, 1, "!slow"))
+	d.Dispatch(ctx, newTestMessage(1, 1, "!slow"))
+	waitFor(t, func() bool { return d.Stats().InFlight == 1 })
+
+	done := make(chan error, 1)
+	go func() { done <- d.Shutdown(ctx) }()
+	waitFor(t, func() bool { return dropped.Load() == 1 })
+	if err := d.Dispatch(ctx, newTestMessage(2, 2, "!slow")); !errors.Is(err, ErrDispatcherClosed) {
+		t.Errorf("Dispatch() after Shutdown error = %v, want %v", err, ErrDispatcherClosed)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("Shutdown() error = %v, want nil", err)
+	}
+	if finished.Load() != 1 {
+		t.Errorf("finished commands = %d, want 1", finished.Load())
+	}
+
+	// команда, не успевающая завершиться к сроку, прерывается
+	d = &Dispatcher[any]{Commands: d.Commands}
+	d.Dispatch(ctx, newTestMessage(3, 3, "!stuck"))
+	waitFor(t, func() bool { return d.Stats().InFlight == 1 })
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	err := d.Shutdown(shutdownCtx)
+	var shutdownErr *ShutdownError
+	if !errors.As(err, &shutdownErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown() error = %v, want ShutdownError with deadline", err)
+	}
+	if len(shutdownErr.Aborted) != 1 || shutdownErr.Aborted[0].Message.Message.PeerID != 3 {
+		t.Errorf("aborted = %+v, want command from peer 3", shutdownErr.Aborted)
+	}
+	// Shutdown возвращается только после выхода прерванных команд
+	if !exited.Load() || d.Stats().InFlight != 0 {
+		t.Errorf("aborted command still running after Shutdown()")
+	}
+}
+
+func TestDispatcherShutdownIgnoredCancel(t *testing.T) {
+	release := make(chan struct{})
+	queue := &QueueSender{Sender: &fakeSender{}, Rate: 1000}
+	d := &Dispatcher[any]{
+		AbortTimeout: 10 * time.Millisecond,
+		Commands: Commands[any]{
+			Prefix: PrefixText("!"),
+			Sender: queue,
+			Handlers: []*CommandHandler[any]{{
+				Pattern: Text("deaf"),
+				// обработчик не проверяет отмену контекста
+				Executor: func(ctx CommandContext[any]) error {
+					<-release
+					return ctx.SendText("поздно")
+				},
+			}},
+		},
+	}
+	defer close(release)
+	d.Dispatch(context.Background(), newTestMessage(4, 4, "!deaf"))
+	waitFor(t, func() bool { return d.Stats().InFlight == 1 })
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- d.Shutdown(shutdownCtx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown() waits for a command that ignores cancellation")
+	}
+
+	var shutdownErr *ShutdownError
+	if !errors.As(err, &shutdownErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown() error = %v, want ShutdownError with deadline", err)
+	}
+	if len(shutdownErr.Running) != 1 || shutdownErr.Running[0].Message.Message.PeerID != 4 {
+		t.Errorf("running = %+v, want command from peer 4", shutdownErr.Running)
+	}
+	// отправитель закрыт и на этом пути
+	if _, err := queue.Send(context.Background(), OutgoingMessage{PeerID: 4}); !errors.Is(err, ErrSenderClosed) {
+		t.Errorf("sender after shutdown error = %v, want %v", err, ErrSenderClosed)
+	}
+}
+
+func TestDispatcherRun(t *testing.T) {
+	queue := &QueueSender{Sender: &fakeSender{}, Rate: 1000}
+	d := &Dispatcher[any]{
+		Commands: Commands[any]{
+			Prefix: PrefixText("!"),
+			Sender: queue,
+			Handlers: []*CommandHandler[any]{{
+				Pattern:  Text("hi"),
+				Executor: func(ctx CommandContext[any]) error { return ctx.SendText("привет") },
+			}},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx) }()
+
+	d.Dispatch(ctx, newTestMessage(1, 1, "!hi"))
+	waitFor(t, func() bool { return d.Stats().Processed == 1 })
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run() error = %v, want nil", err)
+	}
+	// повторная остановка не закрывает отправитель еще раз
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Errorf("repeated Shutdown() error = %v, want nil", err)
+	}
+	if _, err := queue.Send(context.Background(), OutgoingMessage{PeerID: 1, Text: "после"}); !errors.Is(err, ErrSenderClosed) {
+		t.Errorf("sender after shutdown error = %v, want %v", err, ErrSenderClosed)
+	}
+	if stats := queue.Stats(); stats.Sent != 1 {
+		t.Errorf("sent = %d, want 1", stats.Sent)
+	}
+}
@@ -0,0 +1,150 @@
+package vkc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SevereCloud/vksdk/v3/api"
+	"github.com/SevereCloud/vksdk/v3/events"
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+// Поведение команды при изменении сообщения, которым она была вызвана.
+type EditPolicy int
+
+const (
+	// Изменение игнорируется ([Commands.ProcessEdit] возвращает [ErrEditIgnored]).
+	EditIgnore EditPolicy = iota
+	// Команда выполняется заново, ответы отправляются новыми сообщениями.
+	EditRerun
+	// Команда выполняется заново, а вместо отправки новых сообщений изменяются ответы на исходное сообщение (по порядку).
+	// Если новых ответов больше, лишние отправляются как обычно. Для запоминания ответов нужен [Commands.Store].
+	EditRerunAndEditReply
+)
+
+// Время, в течение которого запоминаются ответы команд с [EditRerunAndEditReply].
+var ReplyTrackingTTL = 24 * time.Hour
+
+// Обработка измененного сообщения (событие message_edit) так же, как нового, с флагом [CommandContext.Edited].
+//
+// Выполнение зависит от [CommandHandler.OnEdit] найденной команды: по умолчанию изменения игнорируются,
+// и возвращается [ErrEditIgnored]. Изменения не передаются в ожидания ([CommandContext.Await]) и диалоги.
+//
+// Например, пользователь исправил опечатку "!hepl" на "!help": команда help с политикой [EditRerunAndEditReply]
+// выполнится и изменит свой прошлый ответ, а не отправит новый.
+//
+// Метод следует вызывать из обработчика события [github.com/SevereCloud/vksdk/v3/events.FuncList.MessageEdit]
+// или включить [Commands.HandleEdits].
+func (commands Commands[any]) ProcessEdit(ctx context.Context, vk *api.VK, msg events.MessageEditObject) error {
+	return commands.process(ctx, vk, events.MessageNewObject{Message: object.MessagesMessage(msg)}, true)
+}
+
+// Отправитель, умеющий изменять отправленные сообщения. Ответы команд с [EditRerunAndEditReply] изменяются через
+// [Commands.Sender], если он реализует этот интерфейс (как [VKSender] и [QueueSender]), иначе — через [VKSender].
+type MessageEditor interface {
+	Edit(ctx context.Context, sent SentMessage, msg OutgoingMessage) error
+}
+
+// Изменение сообщения через messages.edit (см. [SentMessage.EditMessage]).
+func (sender VKSender) Edit(ctx context.Context, sent SentMessage, msg OutgoingMessage) error {
+	if sender.VK == nil {
+		return fmt.Errorf("edit error: %w", ErrNoVK)
+	}
+	p := sent.editParams(msg)
+	if ctx != nil {
+		p.WithContext(ctx)
+	}
+	if _, err := sender.VK.MessagesEdit(p); err != nil {
+		return fmt.Errorf("edit error: %w", err)
+	}
+	return nil
+}
+
+// Ответы команды: ранее отправленные (для изменения) и отправленные при текущем выполнении.
+type replyTracker struct {
+	mu       sync.Mutex
+	previous SentMessages
+	sent     SentMessages
+}
+
+// Следующий прошлый ответ, если он был отправлен в беседу peerID. Ответ в другую беседу остается в списке.
+func (tracker *replyTracker) next(peerID int) (SentMessage, bool) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if len(tracker.previous) == 0 || tracker.previous[0].PeerID != peerID {
+		return SentMessage{}, false
+	}
+	prev := tracker.previous[0]
+	tracker.previous = tracker.previous[1:]
+	return prev, true
+}
+
+func (tracker *replyTracker) add(sent SentMessage) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	tracker.sent = append(tracker.sent, sent)
+}
+
+// Отправитель, который вместо отправки изменяет прошлые ответы команды, пока они не закончатся, и запоминает все ответы.
+type replySender struct {
+	Sender
+	tracker *replyTracker
+	editor  MessageEditor
+}
+
+func (sender replySender) Send(ctx context.Context, msg OutgoingMessage) (SentMessage, error) {
+	if prev, ok := sender.tracker.next(msg.PeerID); ok {
+		// если изменить не удалось (например, сообщение удалено), отправляем новое
+		if err := sender.editor.Edit(ctx, prev, msg); err == nil {
+			sender.tracker.add(prev)
+			return prev, nil
+		}
+	}
+	sent, err := sender.Sender.Send(ctx, msg)
+	if err == nil {
+		sender.tracker.add(sent)
+	}
+	return sent, err
+}
+
+// Отправитель для изменения ответов: [Commands.Sender], если он умеет изменять сообщения, иначе [VKSender].
+func (ctx CommandContext[DEPS]) editor() MessageEditor {
+	if editor, ok := ctx.sender().(MessageEditor); ok {
+		return editor
+	}
+	return VKSender{VK: ctx.VK}
+}
+
+// Включение запоминания ответов команды. Для измененного сообщения загружает ответы на исходное.
+// Возвращенная функция сохраняет ответы после выполнения команды. Без [Commands.Store] ничего не делает.
+func (ctx *CommandContext[DEPS]) trackReplies() (func() error, error) {
+	if ctx.commands == nil || ctx.commands.Store == nil {
+		return func() error { return nil }, nil
+	}
+	store := Namespace(ctx.commands.Store, "replies")
+	key := strconv.Itoa(ctx.Message.PeerID) + ":" + strconv.Itoa(ctx.Message.ConversationMessageID)
+
+	tracker := &replyTracker{}
+	if ctx.Edited {
+		if _, err := GetJSON(store, key, &tracker.previous); err != nil {
+			return nil, err
+		}
+	}
+	ctx.replies = tracker
+
+	return func() error {
+		tracker.mu.Lock()
+		sent := tracker.sent
+		tracker.mu.Unlock()
+		if len(sent) == 0 {
+			return nil
+		}
+		if err := SetJSON(store, key, sent, ReplyTrackingTTL); err != nil {
+			return fmt.Errorf("save replies: %w", err)
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,225 @@
+package vkc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SevereCloud/vksdk/v3/events"
+)
+
+func newTestEdit(peerID, fromID, cmid, updateTime int, text string) events.MessageEditObject {
+	msg := newTestMessageCMID(peerID, fromID, cmid, text)
+	msg.Message.UpdateTime = updateTime
+	return events.MessageEditObject(msg.Message)
+}
+
+func TestProcessEditPolicies(t *testing.T) {
+	var edited []bool
+	executor := func(ctx CommandContext[any]) error {
+		edited = append(edited, ctx.Edited)
+		return nil
+	}
+	commands := Commands[any]{
+		Prefix:       PrefixText("!"),
+		Deduplicator: &Deduplicator{},
+		Handlers: []*CommandHandler[any]{
+			{Pattern: Text("ban"), Executor: executor},
+			{Pattern: Text("help"), OnEdit: EditRerun, Executor: executor},
+		},
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		edit events.MessageEditObject
+		err  error
+	}{
+		{"ignored by default", newTestEdit(1, 1, 10, 100, "!ban 5"), ErrEditIgnored},
+		{"rerun", newTestEdit(1, 1, 12, 100, "!help"), nil},
+		// второе изменение того же сообщения — не повтор события
+		{"rerun again", newTestEdit(1, 1, 12, 200, "!help"), nil},
+		{"redelivered edit", newTestEdit(1, 1, 12, 200, "!help"), ErrDuplicateEvent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := commands.ProcessEdit(ctx, nil, tt.edit); !errors.Is(err, tt.err) {
+				t.Errorf("ProcessEdit() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	if err := commands.ProcessCommands(ctx, nil, newTestMessageCMID(1, 1, 13, "!help")); err != nil {
+		t.Fatalf("ProcessCommands() error = %v", err)
+	}
+	if want := []bool{true, true, false}; len(edited) != len(want) || edited[0] != want[0] || edited[1] != want[1] || edited[2] != want[2] {
+		t.Errorf("Edited = %v, want %v", edited, want)
+	}
+}
+
+func TestEditRerunAndEditReply(t *testing.T) {
+	vk, calls := newRecordingVK(t)
+	sender := &fakeSender{}
+	commands := Commands[any]{
+		Prefix: PrefixText("!"),
+		Sender: sender,
+		Store:  NewMemoryStore(),
+		Handlers: []*CommandHandler[any]{
+			{
+				Pattern: Text("echo"),
+				OnEdit:  EditRerunAndEditReply,
+				Executor: func(ctx CommandContext[any]) error {
+					for _, arg := range ctx.Arguments {
+						if err := ctx.SendText(arg); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+			},
+		},
+	}
+	ctx := context.Background()
+
+	if err := commands.ProcessCommands(ctx, vk, newTestMessageCMID(2000000001, 1, 10, "!echo a b")); err != nil {
+		t.Fatalf("ProcessCommands() error = %v", err)
+	}
+	if err := commands.ProcessEdit(ctx, vk, newTestEdit(2000000001, 1, 10, 100, "!echo c d e")); err != nil {
+		t.Fatalf("ProcessEdit() error = %v", err)
+	}
+
+	// два ответа изменены, третий отправлен новым сообщением
+	var texts []string
+	for _, msg := range sender.sent {
+		texts = append(texts, msg.Text)
+	}
+	if len(texts) != 3 || texts[0] != "a" || texts[1] != "b" || texts[2] != "e" {
+		t.Errorf("sent = %q, want [a b e]", texts)
+	}
+	got := calls()
+	expected := []struct{ cmid, text string }{{"1", "c"}, {"2", "d"}}
+	if len(got) != len(expected) {
+		t.Fatalf("calls = %d, want %d", len(got), len(expected))
+	}
+	for i, e := range expected {
+		if got[i].method != "messages.edit" || got[i].params.Get("conversation_message_id") != e.cmid || got[i].params.Get("message") != e.text {
+			t.Errorf("call %d = %s %v, want messages.edit cmid=%s message=%s", i, got[i].method, got[i].params, e.cmid, e.text)
+		}
+	}
+
+	// следующее изменение правит уже все три ответа
+	if err := commands.ProcessEdit(ctx, vk, newTestEdit(2000000001, 1, 10, 200, "!echo f")); err != nil {
+		t.Fatalf("ProcessEdit() error = %v", err)
+	}
+	if got := calls(); len(got) != 3 || got[2].params.Get("message") != "f" {
+		t.Errorf("calls after second edit = %v", got)
+	}
+	if len(sender.sent) != 3 {
+		t.Errorf("sent after second edit = %d, want 3", len(sender.sent))
+	}
+}
+
+func TestEditReplyWithoutStore(t *testing.T) {
+	sender := &fakeSender{}
+	commands := Commands[any]{
+		Prefix: PrefixText("!"),
+		Sender: sender,
+		Handlers: []*CommandHandler[any]{
+			{
+				Pattern:  Text("ping"),
+				OnEdit:   EditRerunAndEditReply,
+				Executor: func(ctx CommandContext[any]) error { return ctx.SendText("pong") },
+			},
+		},
+	}
+	ctx := context.Background()
+	commands.ProcessCommands(ctx, nil, newTestMessageCMID(1, 1, 1, "!ping"))
+	if err := commands.ProcessEdit(ctx, nil, newTestEdit(1, 1, 1, 1, "!ping")); err != nil {
+		t.Fatalf("ProcessEdit() error = %v", err)
+	}
+	if len(sender.sent) != 2 {
+		t.Errorf("sent = %d, want 2", len(sender.sent))
+	}
+}
+
+// Отправитель с изменением сообщений, записывающий изменения.
+type editingSender struct {
+	fakeSender
+	edited []SentMessage
+}
+
+func (s *editingSender) Edit(ctx context.Context, sent SentMessage, msg OutgoingMessage) error {
+	s.edited = append(s.edited, sent)
+	return nil
+}
+
+func TestReplySenderKeepsOtherPeerReplies(t *testing.T) {
+	sender := &editingSender{}
+	tracker := &replyTracker{previous: SentMessages{{PeerID: 1, ConversationMessageID: 5}}}
+	replies := replySender{Sender: sender, tracker: tracker, editor: sender}
+	ctx := context.Background()
+
+	// ответ в другую беседу не забирает прошлый ответ из списка
+	if _, err := replies.Send(ctx, OutgoingMessage{PeerID: 2, Text: "лог"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	sent, err := replies.Send(ctx, OutgoingMessage{PeerID: 1, Text: "ответ"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(sender.sent) != 1 || len(sender.edited) != 1 || sent.ConversationMessageID != 5 {
+		t.Errorf("sent = %d, edited = %v, want 1 new message and edit of cmid 5", len(sender.sent), sender.edited)
+	}
+	if len(tracker.sent) != 2 {
+		t.Errorf("tracked replies = %d, want 2", len(tracker.sent))
+	}
+}
+
+// Хранилище, в которое нельзя записать.
+type readOnlyStore struct {
+	*MemoryStore
+}
+
+var errReadOnly = errors.New("read-only store")
+
+func (store readOnlyStore) Set(key string, value []byte, ttl time.Duration) error {
+	return errReadOnly
+}
+
+func TestEditReplySaveError(t *testing.T) {
+	commands := Commands[any]{
+		Prefix: PrefixText("!"),
+		Sender: &fakeSender{},
+		Store:  readOnlyStore{NewMemoryStore()},
+		Handlers: []*CommandHandler[any]{
+			{
+				Pattern:  Text("ping"),
+				OnEdit:   EditRerunAndEditReply,
+				Executor: func(ctx CommandContext[any]) error { return ctx.SendText("pong") },
+			},
+		},
+	}
+	if err := commands.ProcessCommands(context.Background(), nil, newTestMessageCMID(1, 1, 1, "!ping")); !errors.Is(err, errReadOnly) {
+		t.Errorf("ProcessCommands() error = %v, want %v", err, errReadOnly)
+	}
+}
+
+func TestQueueSenderEdit(t *testing.T) {
+	sender := &editingSender{}
+	queue := &QueueSender{Sender: sender, Rate: 1000}
+	defer queue.Close(context.Background())
+
+	if err := queue.Edit(context.Background(), SentMessage{PeerID: 1, ConversationMessageID: 3}, OutgoingMessage{Text: "новый"}); err != nil {
+		t.Fatalf("Edit() error = %v", err)
+	}
+	if len(sender.edited) != 1 || sender.edited[0].ConversationMessageID != 3 {
+		t.Errorf("edited = %v, want cmid 3", sender.edited)
+	}
+
+	plain := &QueueSender{Sender: &fakeSender{}}
+	defer plain.Close(context.Background())
+	if err := plain.Edit(context.Background(), SentMessage{PeerID: 1}, OutgoingMessage{}); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Edit() without editor error = %v, want %v", err, errors.ErrUnsupported)
+	}
+}
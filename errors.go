@@ -10,4 +10,30 @@ var (
 	ErrEmptyPrefix     = fmt.Errorf("prefix was empty")
 	ErrNoPermissions   = fmt.Errorf("no permissions")
 	ErrEmptyMessage    = fmt.Errorf("empty message")
+
+	ErrDialogsUnavailable = fmt.Errorf("dialog store was not specified")
+	ErrUnknownDialog      = fmt.Errorf("unknown dialog")
+	ErrUnknownDialogStep  = fmt.Errorf("unknown dialog step")
+
+	ErrAwaitUnavailable = fmt.Errorf("awaiter was not specified")
+	ErrAwaitTimeout     = fmt.Errorf("await timed out")
+
+	ErrStoreUnavailable = fmt.Errorf("store was not specified")
+
+	ErrNoVK         = fmt.Errorf("VK API client was not specified")
+	ErrSenderClosed = fmt.Errorf("sender was closed")
+
+	ErrNilRegex            = fmt.Errorf("regex was nil")
+	ErrRegexNoCaptureGroup = fmt.Errorf("regex has no capture group for the remaining text")
+
+	ErrTemplateSyntax     = fmt.Errorf("template syntax error")
+	ErrTemplateUnknownVar = fmt.Errorf("template uses unknown placeholder")
+	ErrTemplateMissingVar = fmt.Errorf("template value was not provided")
+	ErrFormatMismatch     = fmt.Errorf("format verbs do not match arguments")
+
+	ErrDispatchQueueFull = fmt.Errorf("dispatch queue is full")
+	ErrDispatcherClosed  = fmt.Errorf("dispatcher was shut down")
+
+	ErrDuplicateEvent = fmt.Errorf("duplicate event")
+	ErrEditIgnored    = fmt.Errorf("message edit was ignored")
 )
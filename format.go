@@ -0,0 +1,182 @@
+package vkc
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Тип форматирования текста в сообщении VK.
+type FormatType string
+
+const (
+	FormatBold      FormatType = "bold"
+	FormatItalic    FormatType = "italic"
+	FormatUnderline FormatType = "underline"
+	FormatURL       FormatType = "url"
+)
+
+// Участок форматирования. Смещение и длина считаются в единицах UTF-16, как того требует VK.
+type FormatItem struct {
+	Type   FormatType `json:"type"`
+	Offset int        `json:"offset"`
+	Length int        `json:"length"`
+	URL    string     `json:"url,omitempty"`
+}
+
+// Разметка текста сообщения (параметр format_data метода messages.send).
+type FormatData struct {
+	Version int          `json:"version"`
+	Items   []FormatItem `json:"items"`
+}
+
+// Сериализация в JSON для параметра format_data. Позволяет передавать FormatData напрямую в параметры VK SDK.
+func (format FormatData) ToJSON() string {
+	raw, _ := json.Marshal(format)
+	return string(raw)
+}
+
+// Часть разметки, попадающая в участок текста [offset, offset+length) (в единицах UTF-16), со смещениями относительно его начала.
+// Возвращает nil, если в участок ничего не попало.
+func (format *FormatData) slice(offset, length int) *FormatData {
+	if format == nil {
+		return nil
+	}
+	var items []FormatItem
+	for _, item := range format.Items {
+		start := max(item.Offset, offset)
+		end := min(item.Offset+item.Length, offset+length)
+		if start >= end {
+			continue
+		}
+		item.Offset = start - offset
+		item.Length = end - start
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return &FormatData{Version: format.Version, Items: items}
+}
+
+var (
+	escapeMentionRe = regexp.MustCompile(`\[((?:id|club|public)\d+\|)`)
+	// VK разбирает @ и * как упоминание только в начале слова и перед коротким именем, которое начинается с латинской буквы
+	escapeAtRe = regexp.MustCompile(`(^|[^\p{L}\p{N}_.@*])([@*])([A-Za-z])`)
+)
+
+// Экранирование пользовательского текста: разметка упоминаний ([id1|Имя], @durov, *id1) перестает обрабатываться VK.
+//
+// После "[", "@" и "*" вставляется символ нулевой ширины, поэтому текст выглядит так же, но не превращается в упоминание.
+// Остальной текст не меняется: адреса почты (user@mail.ru), выражения (2*x) и квадратные скобки без упоминаний
+// VK не разбирает, поэтому они остаются как есть.
+func EscapeMarkup(text string) string {
+	text = escapeMentionRe.ReplaceAllString(text, "[​$1")
+	return escapeAtRe.ReplaceAllString(text, "$1$2​$3")
+}
+
+// Построитель сообщения с форматированием: жирным, курсивом, подчеркиванием, ссылками и упоминаниями.
+//
+// Весь текст, кроме добавленного через [MessageBuilder.Raw], экранируется (см. [EscapeMarkup]),
+// поэтому в него можно безопасно подставлять данные пользователя.
+//
+// Пример использования:
+//
+//	msg := NewMessage().
+//		Text("Пользователь ").Mention(ctx.Message.FromID, name).
+//		Text(" получил ").Bold("предупреждение").
+//		Text(". Правила: ").Link("тут", "https://example.com/rules").
+//		Message()
+//	_, err := ctx.SendMessage(msg)
+type MessageBuilder struct {
+	text   strings.Builder
+	length int
+	items  []FormatItem
+}
+
+// Создание построителя сообщения.
+func NewMessage() *MessageBuilder {
+	return &MessageBuilder{}
+}
+
+func (b *MessageBuilder) write(text string, formats ...FormatItem) *MessageBuilder {
+	n := utf16Len(text)
+	for _, item := range formats {
+		item.Offset = b.length
+		item.Length = n
+		if n > 0 {
+			b.items = append(b.items, item)
+		}
+	}
+	b.text.WriteString(text)
+	b.length += n
+	return b
+}
+
+// Обычный текст (экранируется).
+func (b *MessageBuilder) Text(text string) *MessageBuilder {
+	return b.write(EscapeMarkup(text))
+}
+
+// Текст без экранирования. Разметка упоминаний в нем будет обработана VK.
+func (b *MessageBuilder) Raw(text string) *MessageBuilder {
+	return b.write(text)
+}
+
+// Жирный текст.
+func (b *MessageBuilder) Bold(text string) *MessageBuilder {
+	return b.write(EscapeMarkup(text), FormatItem{Type: FormatBold})
+}
+
+// Курсив.
+func (b *MessageBuilder) Italic(text string) *MessageBuilder {
+	return b.write(EscapeMarkup(text), FormatItem{Type: FormatItalic})
+}
+
+// Подчеркнутый текст.
+func (b *MessageBuilder) Underline(text string) *MessageBuilder {
+	return b.write(EscapeMarkup(text), FormatItem{Type: FormatUnderline})
+}
+
+// Текст с несколькими типами форматирования сразу, например жирный курсив.
+func (b *MessageBuilder) Styled(text string, types ...FormatType) *MessageBuilder {
+	items := make([]FormatItem, len(types))
+	for i, t := range types {
+		items[i] = FormatItem{Type: t}
+	}
+	return b.write(EscapeMarkup(text), items...)
+}
+
+// Ссылка с текстом.
+func (b *MessageBuilder) Link(text, url string) *MessageBuilder {
+	return b.write(EscapeMarkup(text), FormatItem{Type: FormatURL, URL: url})
+}
+
+func mentionName(name string) string {
+	return strings.NewReplacer("]", "", "|", "", "[", "").Replace(name)
+}
+
+// Упоминание пользователя: [id1|Имя]. Символы разметки из имени удаляются.
+func (b *MessageBuilder) Mention(userID int, name string) *MessageBuilder {
+	return b.write("[id" + strconv.Itoa(userID) + "|" + mentionName(name) + "]")
+}
+
+// Упоминание сообщества: [club1|Название]. Символы разметки из названия удаляются.
+func (b *MessageBuilder) MentionGroup(groupID int, name string) *MessageBuilder {
+	return b.write("[club" + strconv.Itoa(groupID) + "|" + mentionName(name) + "]")
+}
+
+// Итоговый текст и разметка. Если форматирования нет, разметка равна nil.
+func (b *MessageBuilder) Build() (string, *FormatData) {
+	if len(b.items) == 0 {
+		return b.text.String(), nil
+	}
+	return b.text.String(), &FormatData{Version: 1, Items: append([]FormatItem(nil), b.items...)}
+}
+
+// Исходящее сообщение с текстом и разметкой для [CommandContext.SendMessage].
+func (b *MessageBuilder) Message() OutgoingMessage {
+	text, format := b.Build()
+	return OutgoingMessage{Text: text, Format: format}
+}
@@ -0,0 +1,90 @@
+package vkc
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMessageBuilder(t *testing.T) {
+	text, format := NewMessage().
+		Text("😀 ").Bold("жирный").
+		Text(" ").Mention(1, "Павел [Дуров]").
+		Text(" ").Link("ссылка", "https://vk.com").
+		Build()
+
+	expectedText := "😀 жирный [id1|Павел Дуров] ссылка"
+	if text != expectedText {
+		t.Fatalf("text = %q, want %q", text, expectedText)
+	}
+	expected := []FormatItem{
+		{Type: FormatBold, Offset: 3, Length: 6},
+		{Type: FormatURL, Offset: 28, Length: 6, URL: "https://vk.com"},
+	}
+	if format == nil || len(format.Items) != len(expected) {
+		t.Fatalf("format = %+v, want %+v", format, expected)
+	}
+	for i := range expected {
+		if format.Items[i] != expected[i] {
+			t.Errorf("format.Items[%d] = %+v, want %+v", i, format.Items[i], expected[i])
+		}
+	}
+
+	if _, format := NewMessage().Text("просто текст").Build(); format != nil {
+		t.Errorf("format without styles = %+v, want nil", format)
+	}
+}
+
+func TestEscapeMarkup(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[id1|Павел]", "[​id1|Павел]"},
+		{"привет @durov и *id1", "привет @​durov и *​id1"},
+		{"почта: [не упоминание]", "почта: [не упоминание]"},
+		{"5 * 3", "5 * 3"},
+		{"@durov, (*id1)", "@​durov, (*​id1)"},
+		// не разбирается VK как упоминание
+		{"user@mail.ru", "user@mail.ru"},
+		{"2*x + y*3", "2*x + y*3"},
+		{"@Иван и *5", "@Иван и *5"},
+		{"@@durov", "@@durov"},
+		{"[id1]", "[id1]"},
+	}
+	for _, tt := range tests {
+		if actual := EscapeMarkup(tt.input); actual != tt.expected {
+			t.Errorf("EscapeMarkup(%q) = %q, want %q", tt.input, actual, tt.expected)
+		}
+	}
+}
+
+func TestFormatDataJSON(t *testing.T) {
+	format := FormatData{Version: 1, Items: []FormatItem{{Type: FormatItalic, Offset: 0, Length: 2}}}
+	expected := `{"version":1,"items":[{"type":"italic","offset":0,"length":2}]}`
+	if actual := format.ToJSON(); actual != expected {
+		t.Errorf("ToJSON() = %s, want %s", actual, expected)
+	}
+}
+
+func TestSendSplitFormat(t *testing.T) {
+	// жирный участок пересекает границу частей
+	msg := NewMessage().
+		Text(strings.Repeat("a", MaxMessageLength-10) + " ").
+		Bold("bbbbbbbbbbbbbbbbbbbb").
+		Message()
+	sender := &fakeSender{}
+	if _, err := SendSplit(context.Background(), sender, msg); err != nil {
+		t.Fatalf("SendSplit() error = %v", err)
+	}
+	if len(sender.sent) != 2 {
+		t.Fatalf("sent %d parts, want 2", len(sender.sent))
+	}
+	if sender.sent[0].Format != nil {
+		t.Errorf("first part format = %+v, want nil", sender.sent[0].Format)
+	}
+	format := sender.sent[1].Format
+	if format == nil || len(format.Items) != 1 || format.Items[0] != (FormatItem{Type: FormatBold, Offset: 0, Length: 20}) {
+		t.Errorf("second part format = %+v, want bold at 0..20", format)
+	}
+}
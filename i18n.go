@@ -0,0 +1,217 @@
+package vkc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Язык по умолчанию, если в объекте команд не задан [Commands.I18n] или язык не удалось определить.
+const DefaultLocale = "ru"
+
+// Определение языка для пользователя в беседе. Пустая строка означает язык по умолчанию.
+type LocaleResolver func(peerID, userID int) string
+
+// Переводы сообщений бота. Задается в поле [Commands.I18n].
+//
+// Пример использования:
+//
+//	i18n := NewI18n("ru")
+//	for _, path := range []string{"locales/ru.json", "locales/en.json", "locales/uk.po"} {
+//		catalog, err := LoadCatalogFile(path)
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		i18n.Add(catalog)
+//	}
+//	i18n.Resolver = locales.Resolver()
+//	commands.I18n = i18n
+//
+//	// в обработчике:
+//	return ctx.SendText(ctx.T("cooldown", Vars{"count": minutes}))
+type I18n struct {
+	// Язык по умолчанию. Если пуст, используется [DefaultLocale].
+	Default  string
+	Resolver LocaleResolver
+	// Вызывается, если [CommandContext.T] не смог подставить переменные в перевод (например, не передана переменная).
+	OnError  func(locale, key string, err error)
+	catalogs map[string]*Catalog
+}
+
+// Создание набора переводов с языком по умолчанию.
+func NewI18n(defaultLocale string) *I18n {
+	return &I18n{Default: defaultLocale, catalogs: make(map[string]*Catalog)}
+}
+
+// Добавление каталога. Каталог того же языка заменяется.
+func (i18n *I18n) Add(catalog *Catalog) {
+	if i18n.catalogs == nil {
+		i18n.catalogs = make(map[string]*Catalog)
+	}
+	i18n.catalogs[catalog.Locale] = catalog
+}
+
+// Каталог языка или nil, если его нет.
+func (i18n *I18n) Catalog(locale string) *Catalog {
+	if i18n == nil {
+		return nil
+	}
+	return i18n.catalogs[locale]
+}
+
+func (i18n *I18n) defaultLocale() string {
+	if i18n == nil || i18n.Default == "" {
+		return DefaultLocale
+	}
+	return i18n.Default
+}
+
+// Язык для пользователя в беседе.
+func (i18n *I18n) Locale(peerID, userID int) string {
+	if i18n != nil && i18n.Resolver != nil {
+		if locale := i18n.Resolver(peerID, userID); locale != "" {
+			return locale
+		}
+	}
+	return i18n.defaultLocale()
+}
+
+// Перевод сообщения по ключу. Порядок поиска: каталог языка, каталог языка по умолчанию, встроенные сообщения модуля.
+// Если сообщение не найдено, возвращается сам ключ. Если в найденный перевод не удалось подставить переменные,
+// возвращается ключ и ошибка (например, [ErrTemplateMissingVar]).
+func (i18n *I18n) Translate(locale, key string, vars Vars) (string, error) {
+	for _, catalog := range []*Catalog{
+		i18n.Catalog(locale),
+		i18n.Catalog(i18n.defaultLocale()),
+		builtinCatalogs[locale],
+		builtinCatalogs[i18n.defaultLocale()],
+	} {
+		if catalog == nil {
+			continue
+		}
+		tpl, ok := catalog.lookup(key, vars)
+		if !ok {
+			continue
+		}
+		text, err := tpl.Render(vars)
+		if err != nil {
+			return key, fmt.Errorf("translate %q (%s): %w", key, catalog.Locale, err)
+		}
+		return text, nil
+	}
+	return key, nil
+}
+
+func (ctx CommandContext[DEPS]) i18n() *I18n {
+	if ctx.commands == nil {
+		return nil
+	}
+	return ctx.commands.I18n
+}
+
+// Язык автора сообщения в текущей беседе (см. [I18n.Resolver]).
+func (ctx CommandContext[DEPS]) Locale() string {
+	return ctx.i18n().Locale(ctx.Message.PeerID, ctx.Message.FromID)
+}
+
+// Перевод сообщения на язык автора сообщения (см. [I18n.Translate]). Для форм множественного числа передайте значение "count".
+func (ctx CommandContext[DEPS]) Translate(key string, vars Vars) (string, error) {
+	return ctx.i18n().Translate(ctx.Locale(), key, vars)
+}
+
+// Сокращение [CommandContext.Translate] для подстановки прямо в текст. При ошибке возвращает ключ
+// (а не шаблон с неподставленными переменными) и сообщает об ошибке в [I18n.OnError].
+func (ctx CommandContext[DEPS]) T(key string, vars Vars) string {
+	text, err := ctx.Translate(key, vars)
+	if err != nil {
+		if i18n := ctx.i18n(); i18n != nil && i18n.OnError != nil {
+			i18n.OnError(ctx.Locale(), key, err)
+		}
+	}
+	return text
+}
+
+// Помощь по команде на языке автора сообщения (см. [CommandHelp.For]).
+// Если у команды есть названия для этого языка или языка по умолчанию ([CommandHandler.Names]), в Aliases перечисляются только они.
+func (ctx CommandContext[DEPS]) Help(handler *CommandHandler[DEPS]) CommandHelp {
+	locale := ctx.Locale()
+	help := handler.Help.For(locale)
+	if names := handler.localeNames(locale, ctx.i18n().defaultLocale()); len(names) > 0 {
+		help.Aliases = strings.Join(names, ", ")
+		if help.Title == "" {
+			help.Title = names[0]
+		}
+	}
+	return help
+}
+
+// Помощь по всем командам на языке автора сообщения. Скрытые команды и команды, недоступные автору, пропускаются.
+// Подходит для команды "помощь":
+//
+//	for _, help := range ctx.HelpList() {
+//		fmt.Fprintf(&text, "%s — %s\n", help.Title, help.Brief)
+//	}
+func (ctx CommandContext[DEPS]) HelpList() []CommandHelp {
+	if ctx.commands == nil {
+		return nil
+	}
+	var helps []CommandHelp
+	for _, handler := range ctx.commands.Handlers {
+		if handler == nil || handler.Help.Hidden {
+			continue
+		}
+		if handler.AccessCheck != nil && !handler.AccessCheck.Checker(handler, ctx) {
+			continue
+		}
+		helps = append(helps, ctx.Help(handler))
+	}
+	return helps
+}
+
+// Выбор языка пользователями и беседами с хранением в [Store] (пространство имен "locale").
+// Язык пользователя важнее языка беседы.
+type Locales struct {
+	Store Store
+}
+
+func (locales *Locales) store() Store {
+	if locales.Store == nil {
+		return unavailableStore{}
+	}
+	return Namespace(locales.Store, "locale")
+}
+
+func (locales *Locales) set(key, locale string) error {
+	if locale == "" {
+		return locales.store().Delete(key)
+	}
+	return locales.store().Set(key, []byte(locale), 0)
+}
+
+func (locales *Locales) get(key string) string {
+	value, ok, err := locales.store().Get(key)
+	if err != nil || !ok {
+		return ""
+	}
+	return string(value)
+}
+
+// Установка языка пользователя. Пустая строка сбрасывает выбор.
+func (locales *Locales) SetUser(userID int, locale string) error {
+	return locales.set("user:"+strconv.Itoa(userID), locale)
+}
+
+// Установка языка беседы. Пустая строка сбрасывает выбор.
+func (locales *Locales) SetPeer(peerID int, locale string) error {
+	return locales.set("peer:"+strconv.Itoa(peerID), locale)
+}
+
+// Определение языка для [I18n.Resolver]: сначала язык пользователя, затем язык беседы.
+func (locales *Locales) Resolver() LocaleResolver {
+	return func(peerID, userID int) string {
+		if locale := locales.get("user:" + strconv.Itoa(userID)); locale != "" {
+			return locale
+		}
+		return locales.get("peer:" + strconv.Itoa(peerID))
+	}
+}
@@ -0,0 +1,65 @@
+package vkc
+
+// Сообщения, которые отправляет сам модуль. Их можно переопределить в своих каталогах по тем же ключам.
+var builtinMessages = map[string]map[string]map[PluralForm]string{
+	"ru": {
+		"vkc.setprefix.brief": {PluralOther: "Меняет префикс команд в беседе"},
+		"vkc.setprefix.usage": {PluralOther: "setprefix [префикс]"},
+		"vkc.setprefix.too_long": {
+			PluralOne:  "Префикс не может быть длиннее {count} символа.",
+			PluralFew:  "Префикс не может быть длиннее {count} символов.",
+			PluralMany: "Префикс не может быть длиннее {count} символов.",
+		},
+		"vkc.setprefix.changed": {PluralOther: "Префикс изменен на «{prefix}»."},
+	},
+	"uk": {
+		"vkc.setprefix.brief": {PluralOther: "Змінює префікс команд у бесіді"},
+		"vkc.setprefix.usage": {PluralOther: "setprefix [префікс]"},
+		"vkc.setprefix.too_long": {
+			PluralOne:  "Префікс не може бути довшим за {count} символ.",
+			PluralFew:  "Префікс не може бути довшим за {count} символи.",
+			PluralMany: "Префікс не може бути довшим за {count} символів.",
+		},
+		"vkc.setprefix.changed": {PluralOther: "Префікс змінено на «{prefix}»."},
+	},
+	"en": {
+		"vkc.setprefix.brief": {PluralOther: "Changes the command prefix in this chat"},
+		"vkc.setprefix.usage": {PluralOther: "setprefix [prefix]"},
+		"vkc.setprefix.too_long": {
+			PluralOne:   "The prefix cannot be longer than {count} character.",
+			PluralOther: "The prefix cannot be longer than {count} characters.",
+		},
+		"vkc.setprefix.changed": {PluralOther: "The prefix was changed to “{prefix}”."},
+	},
+}
+
+var builtinCatalogs = func() map[string]*Catalog {
+	catalogs := make(map[string]*Catalog, len(builtinMessages))
+	for locale, messages := range builtinMessages {
+		catalog := NewCatalog(locale)
+		for key, forms := range messages {
+			if err := catalog.SetPlural(key, forms); err != nil {
+				panic(err)
+			}
+		}
+		catalogs[locale] = catalog
+	}
+	return catalogs
+}()
+
+// Помощь по встроенной команде на всех языках встроенных сообщений (ключи "vkc.<команда>.brief" и "vkc.<команда>.usage").
+func builtinHelp(command string) CommandHelp {
+	translate := func(locale, field string) string {
+		return builtinMessages[locale]["vkc."+command+"."+field][PluralOther]
+	}
+	help := CommandHelp{
+		Title:     command,
+		Brief:     translate(DefaultLocale, "brief"),
+		Usage:     translate(DefaultLocale, "usage"),
+		Localized: make(map[string]CommandHelp),
+	}
+	for locale := range builtinMessages {
+		help.Localized[locale] = CommandHelp{Brief: translate(locale, "brief"), Usage: translate(locale, "usage")}
+	}
+	return help
+}
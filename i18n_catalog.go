@@ -0,0 +1,301 @@
+package vkc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Каталог переводов одного языка. Сообщения — это шаблоны [Template] с именованными подстановками.
+//
+// Сообщение может иметь формы множественного числа; форма выбирается по значению "count" из переданных [Vars]
+// согласно правилу языка (см. [PluralRules]).
+type Catalog struct {
+	Locale   string
+	messages map[string]map[PluralForm]*Template
+}
+
+// Создание пустого каталога.
+func NewCatalog(locale string) *Catalog {
+	return &Catalog{Locale: locale, messages: make(map[string]map[PluralForm]*Template)}
+}
+
+// Добавление сообщения без форм множественного числа.
+func (catalog *Catalog) Set(key, message string) error {
+	return catalog.SetPlural(key, map[PluralForm]string{PluralOther: message})
+}
+
+// Добавление сообщения с формами множественного числа.
+//
+//	catalog.SetPlural("minutes", map[PluralForm]string{
+//		PluralOne:  "{count} минута",
+//		PluralFew:  "{count} минуты",
+//		PluralMany: "{count} минут",
+//	})
+func (catalog *Catalog) SetPlural(key string, forms map[PluralForm]string) error {
+	parsed := make(map[PluralForm]*Template, len(forms))
+	for form, message := range forms {
+		tpl, err := ParseTemplate(message)
+		if err != nil {
+			return fmt.Errorf("catalog %s: %s: %w", catalog.Locale, key, err)
+		}
+		parsed[form] = tpl
+	}
+	catalog.messages[key] = parsed
+	return nil
+}
+
+// Поиск шаблона сообщения для числа count (если оно передано).
+func (catalog *Catalog) lookup(key string, vars Vars) (*Template, bool) {
+	forms, ok := catalog.messages[key]
+	if !ok || len(forms) == 0 {
+		return nil, false
+	}
+	if len(forms) > 1 {
+		if count, ok := pluralCount(vars["count"]); ok {
+			if tpl, ok := forms[pluralRule(catalog.Locale)(count)]; ok {
+				return tpl, true
+			}
+		}
+	}
+	for _, form := range []PluralForm{PluralOther, PluralMany, PluralFew, PluralOne} {
+		if tpl, ok := forms[form]; ok {
+			return tpl, true
+		}
+	}
+	return nil, false
+}
+
+func pluralCount(value any) (int, bool) {
+	switch n := value.(type) {
+	case int:
+		return n, true
+	case int8:
+		return int(n), true
+	case int16:
+		return int(n), true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case uint:
+		return int(n), true
+	case uint8:
+		return int(n), true
+	case uint16:
+		return int(n), true
+	case uint32:
+		return int(n), true
+	case uint64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
+
+// Разбор каталога из данных, декодируемых функцией unmarshal в map[string]any. Подходит для JSON, YAML, TOML и т.п.
+//
+// Значение сообщения — строка или объект с формами множественного числа (one, few, many, other).
+// Вложенные объекты с другими ключами объединяются через точку: {"setprefix": {"changed": "..."}} дает ключ "setprefix.changed".
+//
+// Пример использования с YAML (модуль не зависит от библиотеки YAML, функция передается явно):
+//
+//	catalog, err := ParseCatalog("ru", data, yaml.Unmarshal)
+func ParseCatalog(locale string, data []byte, unmarshal func([]byte, any) error) (*Catalog, error) {
+	var raw map[string]any
+	if err := unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("catalog %s: %w", locale, err)
+	}
+	catalog := NewCatalog(locale)
+	if err := catalog.addTree("", raw); err != nil {
+		return nil, err
+	}
+	return catalog, nil
+}
+
+// Разбор каталога в формате JSON (см. [ParseCatalog]).
+func ParseJSONCatalog(locale string, data []byte) (*Catalog, error) {
+	return ParseCatalog(locale, data, json.Unmarshal)
+}
+
+func isPluralForms(tree map[string]any) bool {
+	if len(tree) == 0 {
+		return false
+	}
+	for key, value := range tree {
+		switch PluralForm(key) {
+		case PluralOne, PluralFew, PluralMany, PluralOther:
+		default:
+			return false
+		}
+		if _, ok := value.(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (catalog *Catalog) addTree(prefix string, tree map[string]any) error {
+	for key, value := range tree {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch value := value.(type) {
+		case string:
+			if err := catalog.Set(key, value); err != nil {
+				return err
+			}
+		case map[string]any:
+			if !isPluralForms(value) {
+				if err := catalog.addTree(key, value); err != nil {
+					return err
+				}
+				continue
+			}
+			forms := make(map[PluralForm]string, len(value))
+			for form, message := range value {
+				forms[PluralForm(form)] = message.(string)
+			}
+			if err := catalog.SetPlural(key, forms); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("catalog %s: %s: unsupported value type %T", catalog.Locale, key, value)
+		}
+	}
+	return nil
+}
+
+// Разбор каталога в формате gettext (.po). Ключом сообщения служит msgid, а для сообщения с msgctxt — "msgctxt.msgid",
+// как у вложенного ключа в [ParseJSONCatalog]. Формы множественного числа берутся из msgstr[0], msgstr[1], ...
+// (для русского языка: one, few, many). Пустые переводы пропускаются. Повторный ключ считается ошибкой.
+func ParsePOCatalog(locale string, data []byte) (*Catalog, error) {
+	catalog := NewCatalog(locale)
+
+	var (
+		msgctxt string
+		msgid   string
+		plural  bool
+		strs    []string
+		field   *string
+		lineNo  int
+		// строка, на которой начинается текущее сообщение
+		entryLine int
+		// строки, на которых начинаются уже разобранные сообщения, по ключам
+		seen = map[string]int{}
+	)
+	flush := func() error {
+		defer func() { msgctxt, msgid, plural, strs, field = "", "", false, nil, nil }()
+		if msgid == "" || len(strs) == 0 {
+			return nil
+		}
+		key := msgid
+		if msgctxt != "" {
+			key = msgctxt + "." + msgid
+		}
+		if line, ok := seen[key]; ok {
+			return fmt.Errorf("catalog %s: line %d: duplicate message %q (first defined on line %d)", locale, entryLine, key, line)
+		}
+		seen[key] = entryLine
+		if !plural {
+			if strs[0] == "" {
+				return nil
+			}
+			return catalog.Set(key, strs[0])
+		}
+		order := pluralFormsOrder(len(strs))
+		forms := make(map[PluralForm]string, len(strs))
+		for i, message := range strs {
+			if i < len(order) && message != "" {
+				forms[order[i]] = message
+			}
+		}
+		if len(forms) == 0 {
+			return nil
+		}
+		return catalog.SetPlural(key, forms)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		keyword, rest, _ := strings.Cut(line, " ")
+		if strings.HasPrefix(line, `"`) {
+			keyword, rest = "", line
+		}
+		value, err := strconv.Unquote(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("catalog %s: line %d: %w", locale, lineNo, err)
+		}
+
+		switch {
+		case keyword == "":
+			if field == nil {
+				return nil, fmt.Errorf("catalog %s: line %d: unexpected string", locale, lineNo)
+			}
+			*field += value
+		case keyword == "msgctxt":
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			msgctxt = value
+			field = &msgctxt
+			entryLine = lineNo
+		case keyword == "msgid":
+			if len(strs) > 0 {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+			if msgctxt == "" {
+				entryLine = lineNo
+			}
+			msgid = value
+			field = &msgid
+		case keyword == "msgid_plural":
+			plural = true
+			field = new(string)
+		case keyword == "msgstr" || strings.HasPrefix(keyword, "msgstr["):
+			strs = append(strs, value)
+			field = &strs[len(strs)-1]
+		default:
+			return nil, fmt.Errorf("catalog %s: line %d: unknown keyword %q", locale, lineNo, keyword)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("catalog %s: %w", locale, err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return catalog, nil
+}
+
+// Загрузка каталога из файла. Язык берется из имени файла ("ru.json", "en.po"), формат — из расширения: .json или .po.
+// Для YAML используйте [ParseCatalog] с функцией разбора из библиотеки YAML.
+func LoadCatalogFile(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ext := filepath.Ext(path)
+	locale := strings.TrimSuffix(filepath.Base(path), ext)
+	switch strings.ToLower(ext) {
+	case ".json":
+		return ParseJSONCatalog(locale, data)
+	case ".po":
+		return ParsePOCatalog(locale, data)
+	}
+	return nil, fmt.Errorf("catalog %s: unsupported file format %q", path, ext)
+}
@@ -0,0 +1,66 @@
+package vkc
+
+// Форма множественного числа (по классификации CLDR).
+type PluralForm string
+
+const (
+	PluralOne   PluralForm = "one"
+	PluralFew   PluralForm = "few"
+	PluralMany  PluralForm = "many"
+	PluralOther PluralForm = "other"
+)
+
+// Правило выбора формы множественного числа для числа n.
+type PluralRule func(n int) PluralForm
+
+// Правило для русского и украинского языков: 1 минута, 2 минуты, 5 минут, 21 минута, 11 минут.
+func RussianPlural(n int) PluralForm {
+	if n < 0 {
+		n = -n
+	}
+	mod10, mod100 := n%10, n%100
+	switch {
+	case mod10 == 1 && mod100 != 11:
+		return PluralOne
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return PluralFew
+	default:
+		return PluralMany
+	}
+}
+
+// Правило для английского языка: 1 minute, 2 minutes.
+func EnglishPlural(n int) PluralForm {
+	if n == 1 || n == -1 {
+		return PluralOne
+	}
+	return PluralOther
+}
+
+// Правила множественного числа по языкам. Для языков, которых нет в списке, используется [EnglishPlural].
+// Список можно дополнить при инициализации программы.
+var PluralRules = map[string]PluralRule{
+	"ru": RussianPlural,
+	"uk": RussianPlural,
+	"be": RussianPlural,
+	"en": EnglishPlural,
+}
+
+func pluralRule(locale string) PluralRule {
+	if rule, ok := PluralRules[locale]; ok {
+		return rule
+	}
+	return EnglishPlural
+}
+
+// Порядок форм в gettext (msgstr[0], msgstr[1], ...) в зависимости от их количества.
+func pluralFormsOrder(n int) []PluralForm {
+	switch n {
+	case 1:
+		return []PluralForm{PluralOther}
+	case 2:
+		return []PluralForm{PluralOne, PluralOther}
+	default:
+		return []PluralForm{PluralOne, PluralFew, PluralMany, PluralOther}
+	}
+}
@@ -0,0 +1,222 @@
+package vkc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+func TestRussianPlural(t *testing.T) {
+	tests := map[int]PluralForm{
+		0: PluralMany, 1: PluralOne, 2: PluralFew, 4: PluralFew, 5: PluralMany,
+		11: PluralMany, 12: PluralMany, 14: PluralMany, 21: PluralOne, 22: PluralFew,
+		111: PluralMany, 101: PluralOne, -3: PluralFew,
+	}
+	for n, expected := range tests {
+		if actual := RussianPlural(n); actual != expected {
+			t.Errorf("RussianPlural(%d) = %q, want %q", n, actual, expected)
+		}
+	}
+}
+
+func TestParseJSONCatalog(t *testing.T) {
+	catalog, err := ParseJSONCatalog("ru", []byte(`{
+		"hello": "Привет, {name}!",
+		"minutes": {"one": "{count} минута", "few": "{count} минуты", "many": "{count} минут"},
+		"setprefix": {"changed": "Новый префикс: {prefix}"}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseJSONCatalog() error = %v", err)
+	}
+	i18n := NewI18n("ru")
+	i18n.Add(catalog)
+
+	tests := []struct {
+		key      string
+		vars     Vars
+		expected string
+		err      error
+	}{
+		{"hello", Vars{"name": "Иван"}, "Привет, Иван!", nil},
+		{"minutes", Vars{"count": 1}, "1 минута", nil},
+		{"minutes", Vars{"count": 3}, "3 минуты", nil},
+		{"minutes", Vars{"count": 11}, "11 минут", nil},
+		{"setprefix.changed", Vars{"prefix": "/"}, "Новый префикс: /", nil},
+		{"unknown", nil, "unknown", nil},
+		// шаблон с неподставленной переменной не возвращается
+		{"hello", nil, "hello", ErrTemplateMissingVar},
+	}
+	for _, tt := range tests {
+		actual, err := i18n.Translate("ru", tt.key, tt.vars)
+		if actual != tt.expected || !errors.Is(err, tt.err) {
+			t.Errorf("Translate(%q, %v) = %q, %v, want %q, %v", tt.key, tt.vars, actual, err, tt.expected, tt.err)
+		}
+	}
+
+	var reported error
+	i18n.OnError = func(locale, key string, err error) { reported = err }
+	commands := Commands[any]{I18n: i18n}
+	ctx := CommandContext[any]{commands: &commands}
+	if actual := ctx.T("hello", nil); actual != "hello" || !errors.Is(reported, ErrTemplateMissingVar) {
+		t.Errorf("T(hello) = %q, reported %v, want key and %v", actual, reported, ErrTemplateMissingVar)
+	}
+
+	if _, err := ParseCatalog("ru", []byte(`{"bad": 1}`), json.Unmarshal); err == nil {
+		t.Error("ParseCatalog() with number value error = nil, want error")
+	}
+}
+
+func TestParsePOCatalog(t *testing.T) {
+	catalog, err := ParsePOCatalog("uk", []byte(`# переклад
+msgid ""
+msgstr ""
+"Plural-Forms: nplurals=3;\n"
+
+msgid "hello"
+msgstr "Привіт, "
+"{name}!"
+
+msgid "minutes"
+msgid_plural "minutes"
+msgstr[0] "{count} хвилина"
+msgstr[1] "{count} хвилини"
+msgstr[2] "{count} хвилин"
+
+msgid "untranslated"
+msgstr ""
+
+msgctxt "menu"
+msgid "open"
+msgstr "Відкрити меню"
+
+msgid "open"
+msgstr "Відкрити"
+`))
+	if err != nil {
+		t.Fatalf("ParsePOCatalog() error = %v", err)
+	}
+	i18n := NewI18n("ru")
+	i18n.Add(catalog)
+
+	if actual, _ := i18n.Translate("uk", "hello", Vars{"name": "Олена"}); actual != "Привіт, Олена!" {
+		t.Errorf("Translate(hello) = %q", actual)
+	}
+	if actual, _ := i18n.Translate("uk", "minutes", Vars{"count": 5}); actual != "5 хвилин" {
+		t.Errorf("Translate(minutes, 5) = %q", actual)
+	}
+	if actual, _ := i18n.Translate("uk", "untranslated", nil); actual != "untranslated" {
+		t.Errorf("Translate(untranslated) = %q", actual)
+	}
+	// сообщения с одинаковым msgid в разных контекстах не перезаписывают друг друга
+	if actual, _ := i18n.Translate("uk", "menu.open", nil); actual != "Відкрити меню" {
+		t.Errorf("Translate(menu.open) = %q", actual)
+	}
+	if actual, _ := i18n.Translate("uk", "open", nil); actual != "Відкрити" {
+		t.Errorf("Translate(open) = %q", actual)
+	}
+
+	_, err = ParsePOCatalog("uk", []byte(`msgid "open"
+msgstr "Відкрити"
+
+msgid "open"
+msgstr "Відкрити знову"
+`))
+	if err == nil || !strings.Contains(err.Error(), "line 4: duplicate message") {
+		t.Errorf("ParsePOCatalog() with duplicate msgid error = %v", err)
+	}
+}
+
+func TestLocaleResolution(t *testing.T) {
+	locales := &Locales{Store: NewMemoryStore()}
+	i18n := NewI18n("ru")
+	i18n.Resolver = locales.Resolver()
+	commands := Commands[any]{I18n: i18n}
+	ctx := CommandContext[any]{Message: object.MessagesMessage{PeerID: 2000000001, FromID: 1}, commands: &commands}
+
+	if locale := ctx.Locale(); locale != "ru" {
+		t.Errorf("default Locale() = %q, want ru", locale)
+	}
+	locales.SetPeer(2000000001, "uk")
+	if locale := ctx.Locale(); locale != "uk" {
+		t.Errorf("peer Locale() = %q, want uk", locale)
+	}
+	locales.SetUser(1, "en")
+	if locale := ctx.Locale(); locale != "en" {
+		t.Errorf("user Locale() = %q, want en", locale)
+	}
+
+	expected := "The prefix cannot be longer than 10 characters."
+	if actual := ctx.T("vkc.setprefix.too_long", Vars{"count": 10}); actual != expected {
+		t.Errorf("T(builtin) = %q, want %q", actual, expected)
+	}
+	if help := ctx.Help(SetPrefixHandler[any](nil)); help.Brief != "Changes the command prefix in this chat" || help.Title != "setprefix" {
+		t.Errorf("Help(setprefix) = %+v", help)
+	}
+
+	if actual := (CommandContext[any]{}).T("vkc.setprefix.too_long", Vars{"count": 21}); actual != "Префикс не может быть длиннее 21 символа." {
+		t.Errorf("T() without I18n = %q", actual)
+	}
+}
+
+func TestLocalizedCommandNames(t *testing.T) {
+	locales := &Locales{Store: NewMemoryStore()}
+	i18n := NewI18n("ru")
+	i18n.Resolver = locales.Resolver()
+	locales.SetPeer(2, "en")
+	locales.SetPeer(3, "uk")
+
+	var called []string
+	commands := Commands[any]{
+		Prefix: PrefixText("!"),
+		I18n:   i18n,
+		Handlers: []*CommandHandler[any]{
+			{
+				Names: map[string][]string{"ru": {"помощь"}, "en": {"help", "h"}},
+				Help:  CommandHelp{Brief: "Список команд", Localized: map[string]CommandHelp{"en": {Brief: "Command list"}}},
+				Executor: func(ctx CommandContext[any]) error {
+					called = append(called, ctx.Locale()+":"+strings.Join(ctx.Arguments, " "))
+					return nil
+				},
+			},
+		},
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		peerID int
+		text   string
+		err    error
+	}{
+		{1, "!помощь", nil},
+		{1, "!help", ErrCommandNotFound},
+		{2, "!help ban", nil},
+		{2, "!h", nil},
+		{2, "!помощь", ErrCommandNotFound},
+		// для украинского названий нет: используются названия языка по умолчанию
+		{3, "!помощь", nil},
+		{3, "!help", ErrCommandNotFound},
+	}
+	for _, tt := range tests {
+		if err := commands.ProcessCommands(ctx, nil, newTestMessage(tt.peerID, 1, tt.text)); !errors.Is(err, tt.err) {
+			t.Errorf("ProcessCommands(%d, %q) error = %v, want %v", tt.peerID, tt.text, err, tt.err)
+		}
+	}
+	expected := []string{"ru:", "en:ban", "en:", "uk:"}
+	if strings.Join(called, "|") != strings.Join(expected, "|") {
+		t.Errorf("called = %v, want %v", called, expected)
+	}
+
+	cmdCtx := CommandContext[any]{Message: object.MessagesMessage{PeerID: 2, FromID: 1}, commands: &commands}
+	helps := cmdCtx.HelpList()
+	if len(helps) != 1 || helps[0].Title != "help" || helps[0].Aliases != "help, h" || helps[0].Brief != "Command list" {
+		t.Errorf("HelpList() = %+v", helps)
+	}
+	cmdCtx.Message.PeerID = 3
+	if help := cmdCtx.Help(commands.Handlers[0]); help.Title != "помощь" || help.Brief != "Список команд" {
+		t.Errorf("Help() for uk = %+v, want title помощь", help)
+	}
+}
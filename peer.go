@@ -0,0 +1,19 @@
+package vkc
+
+// Начало диапазона ID бесед: peer_id беседы равен 2000000000 + chat_id.
+const ChatPeerIDOffset = 2000000000
+
+// Является ли peer_id беседой.
+func IsChatPeer(peerID int) bool {
+	return peerID > ChatPeerIDOffset
+}
+
+// Является ли peer_id личным диалогом с пользователем (ЛС сообщества).
+func IsUserPeer(peerID int) bool {
+	return peerID > 0 && peerID <= ChatPeerIDOffset
+}
+
+// Является ли peer_id диалогом с другим сообществом.
+func IsGroupPeer(peerID int) bool {
+	return peerID < 0
+}
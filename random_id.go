@@ -0,0 +1,92 @@
+package vkc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"hash/fnv"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/SevereCloud/vksdk/v3/events"
+)
+
+// Данные для вычисления random_id исходящего сообщения.
+type RandomIDSource struct {
+	// ID события VK (event_id), вызвавшего отправку. Может быть пустым, если событие пришло не через VK SDK.
+	EventID               string
+	PeerID                int
+	FromID                int
+	ConversationMessageID int
+	// Порядковый номер отправки в рамках обработки одного события, начиная с 1.
+	Seq int
+}
+
+// Стратегия вычисления random_id. VK не отправляет повторно сообщение с тем же random_id от того же сообщества,
+// поэтому детерминированная стратегия защищает от дублей при повторной обработке события.
+//
+// Указывается в поле [Commands.RandomID]. Есть две готовые стратегии: [CryptoRandomID] (по умолчанию) и [EventRandomID].
+type RandomIDFunc func(src RandomIDSource) int
+
+// Случайный random_id из криптографически стойкого генератора. Каждая отправка уникальна, даже повторная.
+func CryptoRandomID(RandomIDSource) int {
+	var buf [4]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		panic(err)
+	}
+	// VK принимает int32; ноль отключает проверку на дубли, поэтому исключаем его
+	id := int(binary.BigEndian.Uint32(buf[:]) & 0x7fffffff)
+	if id == 0 {
+		id = 1
+	}
+	return id
+}
+
+// Детерминированный random_id, вычисляемый из ID события (или, если его нет, из беседы, автора и номера сообщения) и номера отправки.
+//
+// Повторная обработка того же события (переподключение Long Poll, повтор Callback API, ретрай после ошибки)
+// дает те же random_id, и VK не продублирует ответы.
+func EventRandomID(src RandomIDSource) int {
+	h := fnv.New32a()
+	if src.EventID != "" {
+		h.Write([]byte(src.EventID))
+	} else {
+		h.Write([]byte(strconv.Itoa(src.PeerID) + ":" + strconv.Itoa(src.FromID) + ":" + strconv.Itoa(src.ConversationMessageID)))
+	}
+	h.Write([]byte(":" + strconv.Itoa(src.Seq)))
+	id := int(h.Sum32() & 0x7fffffff)
+	if id == 0 {
+		id = 1
+	}
+	return id
+}
+
+// Безопасное получение ID события из контекста: [events.EventIDFromContext] паникует, если значения нет.
+func eventIDFromContext(ctx context.Context) (eventID string) {
+	if ctx == nil {
+		return ""
+	}
+	defer func() {
+		if recover() != nil {
+			eventID = ""
+		}
+	}()
+	return events.EventIDFromContext(ctx)
+}
+
+// Отправитель, проставляющий random_id каждому сообщению (в том числе каждой части длинного сообщения).
+type randomIDSender struct {
+	Sender
+	strategy RandomIDFunc
+	src      RandomIDSource
+	seq      *atomic.Int64
+}
+
+func (sender randomIDSender) Send(ctx context.Context, msg OutgoingMessage) (SentMessage, error) {
+	if msg.RandomID == 0 {
+		src := sender.src
+		src.Seq = int(sender.seq.Add(1))
+		msg.RandomID = sender.strategy(src)
+	}
+	return sender.Sender.Send(ctx, msg)
+}
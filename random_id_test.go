@@ -0,0 +1,71 @@
+package vkc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/SevereCloud/vksdk/v3/events"
+)
+
+func TestEventRandomID(t *testing.T) {
+	src := RandomIDSource{EventID: "abc", Seq: 1}
+	if EventRandomID(src) != EventRandomID(src) {
+		t.Error("EventRandomID() is not deterministic")
+	}
+	next := src
+	next.Seq = 2
+	if EventRandomID(src) == EventRandomID(next) {
+		t.Error("EventRandomID() is equal for different sends")
+	}
+	fallback := RandomIDSource{PeerID: 1, FromID: 1, ConversationMessageID: 5, Seq: 1}
+	if id := EventRandomID(fallback); id <= 0 {
+		t.Errorf("EventRandomID() without event = %d, want positive", id)
+	}
+	if id := CryptoRandomID(RandomIDSource{}); id <= 0 {
+		t.Errorf("CryptoRandomID() = %d, want positive", id)
+	}
+}
+
+func TestRandomIDOnReprocessing(t *testing.T) {
+	sender := &fakeSender{}
+	commands := Commands[any]{
+		Prefix:   PrefixText("!"),
+		Sender:   sender,
+		RandomID: EventRandomID,
+		Handlers: []*CommandHandler[any]{
+			{
+				Pattern: Text("twice"),
+				Executor: func(ctx CommandContext[any]) error {
+					if err := ctx.SendText("один"); err != nil {
+						return err
+					}
+					return ctx.SendText("два")
+				},
+			},
+		},
+	}
+
+	msg := newTestMessage(1, 1, "!twice")
+	obj, _ := json.Marshal(msg)
+	fl := events.NewFuncList()
+	fl.MessageNew(func(ctx context.Context, msg events.MessageNewObject) {
+		commands.ProcessCommands(ctx, nil, msg)
+	})
+	for range 2 {
+		fl.Handler(context.Background(), events.GroupEvent{Type: events.EventMessageNew, EventID: "event1", Object: obj})
+	}
+
+	if len(sender.sent) != 4 {
+		t.Fatalf("sent %d messages, want 4", len(sender.sent))
+	}
+	if sender.sent[0].RandomID == sender.sent[1].RandomID {
+		t.Error("random_id of different sends is equal")
+	}
+	if sender.sent[0].RandomID != sender.sent[2].RandomID || sender.sent[1].RandomID != sender.sent[3].RandomID {
+		t.Error("random_id changed when the same event was processed again")
+	}
+	if sender.sent[0].RandomID != EventRandomID(RandomIDSource{EventID: "event1", Seq: 1}) {
+		t.Error("random_id was not derived from event_id")
+	}
+}
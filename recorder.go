@@ -0,0 +1,196 @@
+package vkc
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/SevereCloud/vksdk/v3/events"
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+// Записанное входящее событие: одна строка файла записи в формате JSONL.
+type RecordedEvent struct {
+	Time    time.Time `json:"time"`
+	GroupID int       `json:"group_id,omitempty"`
+	EventID string    `json:"event_id,omitempty"`
+	// Событие message_edit (изменение сообщения); в Event.Message записано измененное сообщение.
+	Edit  bool                    `json:"edit,omitempty"`
+	Event events.MessageNewObject `json:"event"`
+}
+
+// Функция сокрытия данных в событии перед записью. Изменяет событие на месте.
+type Redactor func(event *RecordedEvent)
+
+// Применение fn к сообщению, ответу и пересланным сообщениям (рекурсивно). Вложенные сообщения копируются, чтобы не изменить исходное событие.
+func redactMessage(msg *object.MessagesMessage, fn func(msg *object.MessagesMessage)) {
+	fn(msg)
+	if msg.ReplyMessage != nil {
+		reply := *msg.ReplyMessage
+		redactMessage(&reply, fn)
+		msg.ReplyMessage = &reply
+	}
+	if len(msg.FwdMessages) > 0 {
+		fwd := make([]object.MessagesMessage, len(msg.FwdMessages))
+		for i, m := range msg.FwdMessages {
+			redactMessage(&m, fn)
+			fwd[i] = m
+		}
+		msg.FwdMessages = fwd
+	}
+}
+
+// Сокрытие текста сообщения и пересланных сообщений: каждый символ, кроме пробельных, заменяется на "*".
+// Если keepCommand равен true, первое слово (префикс и команда) сохраняется, чтобы при воспроизведении находился тот же обработчик.
+func RedactText(keepCommand bool) Redactor {
+	mask := func(text string) string {
+		return strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return r
+			}
+			return '*'
+		}, text)
+	}
+	return func(event *RecordedEvent) {
+		text := event.Event.Message.Text
+		redactMessage(&event.Event.Message, func(msg *object.MessagesMessage) {
+			msg.Text = mask(msg.Text)
+		})
+		if keepCommand {
+			command, rest, _ := strings.Cut(text, " ")
+			event.Event.Message.Text = command
+			if rest != "" {
+				event.Event.Message.Text += " " + mask(rest)
+			}
+		}
+	}
+}
+
+// Замена идентификаторов пользователей на псевдонимы. Один и тот же пользователь всегда получает один и тот же псевдоним
+// (хеш идентификатора с солью salt), поэтому при воспроизведении сохраняется, кто какое сообщение отправил.
+// Идентификаторы сообществ (отрицательные) и бесед не меняются; peer_id личных сообщений заменяется вместе с автором.
+func RedactUserIDs(salt string) Redactor {
+	pseudonym := func(id int) int {
+		if !IsUserPeer(id) {
+			return id
+		}
+		h := fnv.New32a()
+		h.Write([]byte(salt + strconv.Itoa(id)))
+		return int(h.Sum32()%(ChatPeerIDOffset-1)) + 1
+	}
+	return func(event *RecordedEvent) {
+		redactMessage(&event.Event.Message, func(msg *object.MessagesMessage) {
+			msg.FromID = pseudonym(msg.FromID)
+			msg.PeerID = pseudonym(msg.PeerID)
+		})
+	}
+}
+
+// Запись входящих событий в JSONL. Задается в поле [Commands.Recorder]; каждое событие записывается в начале [Commands.ProcessCommands].
+//
+// Пример использования:
+//
+//	recorder, err := OpenRecorder("events.jsonl")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer recorder.Close()
+//	recorder.Redact = []Redactor{RedactText(true), RedactUserIDs("соль")}
+//	commands.Recorder = recorder
+//
+// Ошибки записи не прерывают обработку сообщений; первую из них возвращает [Recorder.Err].
+type Recorder struct {
+	// Функции сокрытия данных, применяемые по порядку к каждому событию.
+	Redact []Redactor
+	// Источник времени событий. Если не задан, используются системные часы.
+	Clock Clock
+
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+	err    error
+}
+
+// Создание записи в поток w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w}
+}
+
+// Создание записи в файл. Если файл существует, события дописываются в его конец.
+func OpenRecorder(path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{w: file, closer: file}, nil
+}
+
+// Запись события message_new.
+func (recorder *Recorder) Record(ctx context.Context, msg events.MessageNewObject) error {
+	return recorder.record(ctx, msg, false)
+}
+
+func (recorder *Recorder) record(ctx context.Context, msg events.MessageNewObject, edit bool) error {
+	event := RecordedEvent{
+		Time:    recorder.Clock.now(),
+		GroupID: groupIDFromContext(ctx),
+		EventID: eventIDFromContext(ctx),
+		Edit:    edit,
+		Event:   msg,
+	}
+	for _, redact := range recorder.Redact {
+		redact(&event)
+	}
+	line, err := json.Marshal(event)
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if err == nil {
+		_, err = recorder.w.Write(append(line, '\n'))
+	}
+	if err != nil && recorder.err == nil {
+		recorder.err = err
+	}
+	return err
+}
+
+// Первая ошибка записи.
+func (recorder *Recorder) Err() error {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return recorder.err
+}
+
+// Закрытие файла записи (для записи, созданной через [OpenRecorder]).
+func (recorder *Recorder) Close() error {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if recorder.closer == nil {
+		return nil
+	}
+	return recorder.closer.Close()
+}
+
+// Чтение записанных событий из JSONL.
+func ReadRecordedEvents(r io.Reader) ([]RecordedEvent, error) {
+	var recorded []RecordedEvent
+	decoder := json.NewDecoder(r)
+	for {
+		var event RecordedEvent
+		err := decoder.Decode(&event)
+		if err == io.EOF {
+			return recorded, nil
+		}
+		if err != nil {
+			return recorded, err
+		}
+		recorded = append(recorded, event)
+	}
+}
@@ -0,0 +1,177 @@
+package vkc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+func TestRecorderRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewRecorder(&buf)
+	recorder.Redact = []Redactor{RedactText(true), RedactUserIDs("salt")}
+
+	msg := newTestMessage(5, 5, "!ban Иван Петров")
+	msg.Message.ReplyMessage = &object.MessagesMessage{FromID: 7, PeerID: 5, Text: "секрет"}
+	if err := recorder.Record(context.Background(), msg); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := recorder.Record(context.Background(), newTestMessage(2000000001, 5, "!help")); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	recorded, err := ReadRecordedEvents(&buf)
+	if err != nil {
+		t.Fatalf("ReadRecordedEvents() error = %v", err)
+	}
+	if len(recorded) != 2 {
+		t.Fatalf("recorded %d events, want 2", len(recorded))
+	}
+
+	first := recorded[0].Event.Message
+	if first.Text != "!ban **** ******" {
+		t.Errorf("redacted text = %q", first.Text)
+	}
+	if first.ReplyMessage.Text != "******" || msg.Message.ReplyMessage.Text != "секрет" {
+		t.Errorf("reply text = %q, original = %q", first.ReplyMessage.Text, msg.Message.ReplyMessage.Text)
+	}
+	if first.FromID == 5 || first.FromID != first.PeerID {
+		t.Errorf("direct message ids = %d/%d, want equal pseudonyms", first.FromID, first.PeerID)
+	}
+	second := recorded[1].Event.Message
+	if second.FromID != first.FromID || second.PeerID != 2000000001 {
+		t.Errorf("second event ids = %d/%d, want %d/2000000001", second.FromID, second.PeerID, first.FromID)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var steps []string
+	commands := newTestDialogCommands(&steps)
+	commands.Dialogs[0].Timeout = time.Minute
+	commands.Handlers = append(commands.Handlers, &CommandHandler[any]{
+		Pattern: Text("time"),
+		Executor: func(ctx CommandContext[any]) error {
+			return ctx.SendText(ctx.Now().Format(time.TimeOnly))
+		},
+	})
+
+	clock := &ManualClock{}
+	var buf bytes.Buffer
+	commands.Recorder = NewRecorder(&buf)
+	commands.Recorder.Clock = clock.Now
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	// между вторым запуском опроса и ответом проходит больше минуты, диалог истекает
+	offsets := []time.Duration{0, 10 * time.Second, 20 * time.Second, 30 * time.Second, 2 * time.Minute, 3 * time.Minute}
+	for i, text := range []string{"!survey", "Иван", "25", "!survey", "Петр", "!time"} {
+		clock.Set(start.Add(offsets[i]))
+		commands.Recorder.Record(context.Background(), newTestMessage(1, 1, text))
+	}
+
+	commands.Recorder = nil
+	results, err := Replay(context.Background(), commands, strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if len(results) != 6 {
+		t.Fatalf("replayed %d events, want 6", len(results))
+	}
+	if !errors.Is(results[4].Err, ErrNoPrefix) {
+		t.Errorf("answer after timeout error = %v, want %v", results[4].Err, ErrNoPrefix)
+	}
+	if sent := results[5].Sent; len(sent) != 1 || sent[0].Text != "12:03:00" {
+		t.Errorf("!time sent = %+v, want 12:03:00", sent)
+	}
+	expected := "start|name:Иван|age:Иван:25|start"
+	if actual := strings.Join(steps, "|"); actual != expected {
+		t.Errorf("steps = %s, want %s", actual, expected)
+	}
+}
+
+func TestReplayEventContext(t *testing.T) {
+	commands := Commands[any]{
+		Prefix:       PrefixText("!"),
+		Awaiter:      &Awaiter{},
+		Deduplicator: &Deduplicator{},
+		Handlers: []*CommandHandler[any]{
+			{
+				Pattern: Text("event"),
+				Executor: func(ctx CommandContext[any]) error {
+					return ctx.SendText("%d %s", groupIDFromContext(ctx.Context), eventIDFromContext(ctx.Context))
+				},
+			},
+			{
+				Pattern: Text("ask"),
+				Executor: func(ctx CommandContext[any]) error {
+					_, err := ctx.Await(0, nil)
+					return err
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	recorder := NewRecorder(&buf)
+	for i, text := range []string{"!event", "!event", "!ask"} {
+		// второе событие — повторная доставка первого (тот же event_id)
+		eventID := []string{"ev1", "ev1", "ev2"}[i]
+		ctx := recordedEventContext(context.Background(), RecordedEvent{GroupID: 7, EventID: eventID})
+		msg := newTestMessage(1, 1, text)
+		msg.Message.ConversationMessageID = i + 1
+		recorder.Record(ctx, msg)
+	}
+
+	results, err := Replay(context.Background(), commands, strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if sent := results[0].Sent; len(sent) != 1 || sent[0].Text != "7 ev1" {
+		t.Errorf("!event sent = %+v, want 7 ev1", sent)
+	}
+	if !errors.Is(results[1].Err, ErrDuplicateEvent) {
+		t.Errorf("redelivered event error = %v, want %v", results[1].Err, ErrDuplicateEvent)
+	}
+	// ожидание не зависает, а сразу завершается
+	if !errors.Is(results[2].Err, ErrAwaitUnavailable) {
+		t.Errorf("!ask error = %v, want %v", results[2].Err, ErrAwaitUnavailable)
+	}
+}
+
+func TestReplayTwice(t *testing.T) {
+	dedup := &Deduplicator{Store: NewMemoryStore(), TTL: time.Hour}
+	commands := Commands[any]{
+		Prefix:       PrefixText("!"),
+		Deduplicator: dedup,
+		Handlers: []*CommandHandler[any]{
+			{Pattern: Text("ping"), Executor: func(ctx CommandContext[any]) error { return ctx.SendText("pong") }},
+		},
+	}
+
+	var buf bytes.Buffer
+	recorder := NewRecorder(&buf)
+	for _, eventID := range []string{"ev1", "ev1"} {
+		recorder.Record(recordedEventContext(context.Background(), RecordedEvent{EventID: eventID}), newTestMessageCMID(1, 1, 1, "!ping"))
+	}
+
+	for run := range 2 {
+		results, err := Replay(context.Background(), commands, strings.NewReader(buf.String()))
+		if err != nil {
+			t.Fatalf("Replay() error = %v", err)
+		}
+		// повторная доставка внутри журнала отсеивается, а прошлое воспроизведение не влияет на результат
+		if len(results) != 2 || results[0].Err != nil || len(results[0].Sent) != 1 || !errors.Is(results[1].Err, ErrDuplicateEvent) {
+			t.Errorf("replay %d results = %+v, want pong and duplicate", run+1, results)
+		}
+	}
+
+	// хранилище рабочего Deduplicator не затронуто
+	ctx := recordedEventContext(context.Background(), RecordedEvent{EventID: "ev1"})
+	if duplicate, err := dedup.Check(ctx, newTestMessageCMID(1, 1, 1, "!ping"), time.Now()); err != nil || duplicate {
+		t.Errorf("Check() after replay = %v, %v, want not duplicate", duplicate, err)
+	}
+}
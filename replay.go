@@ -0,0 +1,106 @@
+package vkc
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/SevereCloud/vksdk/v3/events"
+)
+
+// Результат воспроизведения одного события.
+type ReplayResult struct {
+	Event RecordedEvent
+	// Ошибка [Commands.ProcessCommands].
+	Err error
+	// Сообщения, отправленные при обработке события.
+	Sent []OutgoingMessage
+}
+
+// Отправитель для воспроизведения: запоминает сообщения и назначает им идентификаторы по порядку.
+type replaySender struct {
+	mu        sync.Mutex
+	sent      []OutgoingMessage
+	messageID int
+}
+
+func (sender *replaySender) Send(_ context.Context, msg OutgoingMessage) (SentMessage, error) {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	sender.sent = append(sender.sent, msg)
+	sender.messageID++
+	return SentMessage{PeerID: msg.PeerID, MessageID: sender.messageID, ConversationMessageID: sender.messageID}, nil
+}
+
+func (sender *replaySender) take() []OutgoingMessage {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	sent := sender.sent
+	sender.sent = nil
+	return sent
+}
+
+// Воспроизведение записанных событий (см. [Recorder]) через [Commands.ProcessCommands].
+//
+// События обрабатываются по очереди без VK API: отправленные сообщения перехватываются и возвращаются в результатах,
+// а часы объекта команд ([Commands.Clock]) перед каждым событием переводятся на время его записи.
+// Поэтому сроки диалогов ведут себя так же, как при записи, а результат не зависит от времени запуска.
+// ID сообщества и ID события из записи передаются в контекст обработки, как при получении события из Long Poll или Callback API.
+// Вызовы VK API напрямую (реакции, статус активности и т.п.) завершаются ошибкой [ErrNoVK].
+//
+// Ожидания ответа не воспроизводятся: следующее событие обрабатывается только после предыдущего, поэтому
+// [CommandContext.Await] сразу возвращает [ErrAwaitUnavailable], а ответы обрабатываются как обычные сообщения.
+//
+// Поля Sender, Clock, Recorder и Awaiter переданного объекта команд заменяются только на время воспроизведения.
+// Deduplicator заменяется новым с теми же TTL и MaxEntries, но пустым и хранящим ключи в памяти: повторная доставка
+// внутри журнала отсеивается, как при записи, а события не отбрасываются из-за обработки в работающем боте
+// или прошлого воспроизведения и не отмечаются в его хранилище.
+// У хранилищ свои часы: для [MemoryStore] их можно задать в поле [MemoryStore.Clock].
+//
+// Пример использования:
+//
+//	file, _ := os.Open("events.jsonl")
+//	results, err := Replay(ctx, commands, file)
+//	for _, result := range results {
+//		fmt.Println(result.Event.Event.Message.Text, result.Err, len(result.Sent))
+//	}
+func Replay[DEPS any](ctx context.Context, commands Commands[DEPS], r io.Reader) ([]ReplayResult, error) {
+	recorded, err := ReadRecordedEvents(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sender := &replaySender{}
+	clock := &ManualClock{}
+	commands.Sender = sender
+	commands.Clock = clock.Now
+	commands.Recorder = nil
+	commands.Awaiter = nil
+	if dedup := commands.Deduplicator; dedup != nil {
+		commands.Deduplicator = &Deduplicator{TTL: dedup.TTL, MaxEntries: dedup.MaxEntries}
+	}
+
+	results := make([]ReplayResult, 0, len(recorded))
+	for _, event := range recorded {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		clock.Set(event.Time)
+		err := commands.process(recordedEventContext(ctx, event), nil, event.Event, event.Edit)
+		results = append(results, ReplayResult{Event: event, Err: err, Sent: sender.take()})
+	}
+	return results, nil
+}
+
+// Контекст с ID сообщества и ID события из записи. Значения кладутся в контекст обработчиком событий vksdk,
+// чтобы [events.GroupIDFromContext] и [events.EventIDFromContext] работали так же, как для настоящих событий.
+func recordedEventContext(ctx context.Context, event RecordedEvent) context.Context {
+	const replayEvent events.EventType = "vkc_replay"
+	eventCtx := ctx
+	handler := events.NewFuncList()
+	handler.OnEvent(replayEvent, func(ctx context.Context, _ events.GroupEvent) {
+		eventCtx = ctx
+	})
+	handler.Handler(ctx, events.GroupEvent{Type: replayEvent, GroupID: event.GroupID, EventID: event.EventID})
+	return eventCtx
+}
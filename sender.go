@@ -0,0 +1,157 @@
+package vkc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/SevereCloud/vksdk/v3/api"
+	"github.com/SevereCloud/vksdk/v3/api/params"
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+// Исходящее сообщение. Используется методами отправки [CommandContext] и интерфейсом [Sender].
+type OutgoingMessage struct {
+	PeerID int
+	Text   string
+	// ID сообщения, на которое нужно ответить. Если равно нулю, сообщение отправляется без ответа.
+	ReplyTo int
+	// Вложения в формате VK API, например "photo-1_2" или "doc-1_3". Для загрузки файлов см. [CommandContext.Upload].
+	Attachments []string
+	Keyboard    *object.MessagesKeyboard
+	// Разметка текста (жирный, курсив, ссылки). Удобнее всего получать через [MessageBuilder].
+	Format *FormatData
+	// Значение random_id. Если равно нулю, вычисляется по стратегии [Commands.RandomID] (или [CryptoRandomID] в [VKSender]).
+	RandomID int
+}
+
+// Отправитель сообщений. Через него проходят все методы отправки [CommandContext].
+//
+// По умолчанию используется [VKSender]; свою реализацию можно указать в поле [Commands.Sender], например, чтобы подменить VK в тестах.
+type Sender interface {
+	Send(ctx context.Context, msg OutgoingMessage) (SentMessage, error)
+}
+
+// Отправленные сообщения. Длинный текст может быть отправлен несколькими сообщениями (см. [SendSplit]).
+type SentMessages []SentMessage
+
+// Последняя отправленная часть (с клавиатурой и вложениями). Если сообщений нет, возвращает пустой SentMessage.
+func (sent SentMessages) Last() SentMessage {
+	if len(sent) == 0 {
+		return SentMessage{}
+	}
+	return sent[len(sent)-1]
+}
+
+// Отправитель сообщений через VK API (метод messages.send).
+type VKSender struct {
+	VK *api.VK
+}
+
+// Параметры messages.send для сообщения.
+func (msg OutgoingMessage) Params() api.Params {
+	b := params.NewMessagesSendBuilder()
+	b.PeerIDs([]int{msg.PeerID})
+	b.RandomID(msg.RandomID)
+	if msg.Text != "" {
+		b.Message(msg.Text)
+	}
+	if msg.ReplyTo != 0 {
+		b.ReplyTo(msg.ReplyTo)
+	}
+	if len(msg.Attachments) > 0 {
+		b.Attachment(strings.Join(msg.Attachments, ","))
+	}
+	if msg.Keyboard != nil {
+		b.Keyboard(msg.Keyboard)
+	}
+	if msg.Format != nil {
+		b.Params["format_data"] = msg.Format
+	}
+	return b.Params
+}
+
+func (sender VKSender) Send(ctx context.Context, msg OutgoingMessage) (SentMessage, error) {
+	if sender.VK == nil {
+		return SentMessage{}, fmt.Errorf("send error: %w", ErrNoVK)
+	}
+	if msg.RandomID == 0 {
+		msg.RandomID = CryptoRandomID(RandomIDSource{})
+	}
+	p := msg.Params()
+	if ctx != nil {
+		p.WithContext(ctx)
+	}
+	resp, err := sender.VK.MessagesSendPeerIDs(p)
+	if err != nil {
+		return SentMessage{}, fmt.Errorf("send error: %w", err)
+	}
+	if len(resp) == 0 {
+		return SentMessage{}, fmt.Errorf("send error: empty response")
+	}
+	if resp[0].Error.Code != 0 {
+		return SentMessage{}, fmt.Errorf("send error: %w", resp[0].Error)
+	}
+	return SentMessage{
+		PeerID:                resp[0].PeerID,
+		MessageID:             resp[0].MessageID,
+		ConversationMessageID: resp[0].ConversationMessageID,
+	}, nil
+}
+
+func (ctx CommandContext[DEPS]) sender() Sender {
+	if ctx.commands != nil && ctx.commands.Sender != nil {
+		return ctx.commands.Sender
+	}
+	return VKSender{VK: ctx.VK}
+}
+
+func (ctx CommandContext[DEPS]) randomIDSender() Sender {
+	strategy := RandomIDFunc(CryptoRandomID)
+	if ctx.commands != nil && ctx.commands.RandomID != nil {
+		strategy = ctx.commands.RandomID
+	}
+	seq := ctx.sendSeq
+	if seq == nil {
+		seq = new(atomic.Int64)
+	}
+	return randomIDSender{
+		Sender:   ctx.sender(),
+		strategy: strategy,
+		seq:      seq,
+		src: RandomIDSource{
+			EventID:               eventIDFromContext(ctx.Context),
+			PeerID:                ctx.Message.PeerID,
+			FromID:                ctx.Message.FromID,
+			ConversationMessageID: ctx.Message.ConversationMessageID,
+		},
+	}
+}
+
+// Отправка произвольного сообщения. Если PeerID не указан, сообщение отправляется в беседу, из которой пришла команда.
+//
+// Текст длиннее [MaxMessageLength] отправляется несколькими сообщениями (см. [SendSplit]); возвращаются все отправленные части.
+// Через них можно позднее изменить или удалить сообщение:
+//
+//	sent, err := ctx.SendMessage(OutgoingMessage{Text: "Загрузка…"})
+//	if err != nil {
+//		return err
+//	}
+//	// долгая работа...
+//	return sent.Last().Edit("Готово!")
+func (ctx CommandContext[DEPS]) SendMessage(msg OutgoingMessage) (SentMessages, error) {
+	if msg.PeerID == 0 {
+		msg.PeerID = ctx.Message.PeerID
+	}
+	parent := ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	sender := ctx.randomIDSender()
+	if ctx.replies != nil {
+		sender = replySender{Sender: sender, tracker: ctx.replies, editor: ctx.editor()}
+	}
+	sent, err := SendSplit(parent, sender, msg)
+	return sent.bind(ctx.sender(), ctx.VK), err
+}
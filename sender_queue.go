@@ -0,0 +1,713 @@
+package vkc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SevereCloud/vksdk/v3/api"
+)
+
+// Максимальное число вызовов API в одном запросе execute.
+const MaxExecuteCalls = 25
+
+// Отправитель, умеющий отправлять несколько сообщений одним запросом (например, через execute).
+//
+// Возвращает результат и ошибку для каждого сообщения в том же порядке.
+type BatchSender interface {
+	Sender
+	SendBatch(ctx context.Context, msgs []OutgoingMessage) ([]SentMessage, []error)
+}
+
+// Отправка до [MaxExecuteCalls] сообщений одним вызовом execute.
+func (sender VKSender) SendBatch(ctx context.Context, msgs []OutgoingMessage) ([]SentMessage, []error) {
+	results := make([]SentMessage, len(msgs))
+	errs := make([]error, len(msgs))
+	fail := func(err error) ([]SentMessage, []error) {
+		for i := range errs {
+			errs[i] = fmt.Errorf("send error: %w", err)
+		}
+		return results, errs
+	}
+	if sender.VK == nil {
+		return fail(ErrNoVK)
+	}
+	if len(msgs) > MaxExecuteCalls {
+		return fail(fmt.Errorf("batch of %d messages exceeds %d calls", len(msgs), MaxExecuteCalls))
+	}
+
+	calls := make([]string, len(msgs))
+	for i, msg := range msgs {
+		if msg.RandomID == 0 {
+			msg.RandomID = CryptoRandomID(RandomIDSource{})
+		}
+		args := make(map[string]string)
+		for key, value := range msg.Params() {
+			args[key] = api.FmtValue(value, 0)
+		}
+		raw, err := json.Marshal(args)
+		if err != nil {
+			return fail(err)
+		}
+		calls[i] = "API.messages.send(" + string(raw) + ")"
+	}
+
+	var resp []json.RawMessage
+	p := api.Params{}
+	if ctx != nil {
+		p.WithContext(ctx)
+	}
+	err := sender.VK.ExecuteWithArgs("return ["+strings.Join(calls, ",")+"];", p, &resp)
+	var execErrs *api.ExecuteErrors
+	if err != nil && !errors.As(err, &execErrs) {
+		return fail(err)
+	}
+
+	// execute_errors перечислены в порядке неудачных вызовов, а на их месте в ответе стоит false
+	nextErr := 0
+	for i := range msgs {
+		var sent api.MessagesSendUserIDsResponse
+		if i >= len(resp) || json.Unmarshal(resp[i], &sent) != nil || len(sent) == 0 {
+			errs[i] = errors.New("send error: execute call failed")
+			if execErrs != nil && nextErr < len(*execErrs) {
+				e := (*execErrs)[nextErr]
+				errs[i] = fmt.Errorf("send error: %w", &api.Error{Code: e.Code, Message: e.Msg})
+				nextErr++
+			}
+			continue
+		}
+		if sent[0].Error.Code != 0 {
+			errs[i] = fmt.Errorf("send error: %w", sent[0].Error)
+			continue
+		}
+		results[i] = SentMessage{
+			PeerID:                sent[0].PeerID,
+			MessageID:             sent[0].MessageID,
+			ConversationMessageID: sent[0].ConversationMessageID,
+		}
+	}
+	return results, errs
+}
+
+// Ограничитель частоты запросов по алгоритму token bucket.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+func (bucket *tokenBucket) wait(ctx context.Context) error {
+	// отмененный запрос не расходует токен
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for {
+		bucket.mu.Lock()
+		now := time.Now()
+		bucket.tokens = min(bucket.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*bucket.rate)
+		bucket.last = now
+		if bucket.tokens >= 1 {
+			bucket.tokens--
+			bucket.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - bucket.tokens) / bucket.rate * float64(time.Second))
+		bucket.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Является ли ошибка VK временной: превышение частоты запросов (6) или flood control (9).
+func isRetryableSendError(err error) bool {
+	return errors.Is(err, api.ErrTooMany) || errors.Is(err, api.ErrFlood)
+}
+
+type queueItem struct {
+	ctx context.Context
+	msg OutgoingMessage
+	// действие с отправленным сообщением (изменение, удаление и т. п.) в беседе peer; nil для отправки нового
+	action   func(ctx context.Context) error
+	peer     int
+	done     chan queueResult
+	canceled atomic.Bool
+	// номер следующей попытки и время, не раньше которого ее можно сделать
+	attempt int
+	due     time.Time
+}
+
+// Беседа сообщения: порядок сохраняется в пределах беседы.
+func (item *queueItem) peerID() int {
+	if item.action != nil {
+		return item.peer
+	}
+	return item.msg.PeerID
+}
+
+type queueResult struct {
+	sent SentMessage
+	err  error
+}
+
+// Статистика очереди [QueueSender].
+type QueueStats struct {
+	// Число сообщений, ожидающих отправки.
+	Depth int
+	// Наибольшая длина очереди за все время.
+	MaxDepth int
+	Sent     uint64
+	Failed   uint64
+	Retried  uint64
+	// Число запросов execute, отправивших больше одного сообщения.
+	Batches uint64
+}
+
+// Очередь исходящих сообщений с ограничением частоты запросов и повторами при ошибках лимитов VK.
+//
+// Все сообщения отправляются одним обработчиком очереди в порядке поступления, поэтому порядок сообщений в каждой беседе сохраняется.
+// Частота запросов ограничивается по алгоритму token bucket; при ошибках "Too many requests per second" (6) и flood control (9)
+// отправка повторяется с экспоненциальной задержкой. Пока сообщение ждет повтора, следующие сообщения в ту же беседу
+// придерживаются, а сообщения в другие беседы отправляются без задержки. Если включен Batch и Sender реализует [BatchSender] (как [VKSender]),
+// сообщения в разные беседы объединяются в один запрос execute.
+//
+// Лимиты VK считаются для каждого токена, поэтому на каждый токен сообщества нужна своя очередь:
+//
+//	queue := &QueueSender{Sender: VKSender{VK: vk}, Batch: true}
+//	defer queue.Close(context.Background())
+//	commands := Commands[any]{
+//		Sender: queue,
+//		// ...
+//	}
+//
+// Метод Send блокируется до отправки сообщения (или отмены контекста) и возвращает результат отправки.
+type QueueSender struct {
+	Sender Sender
+	// Число запросов в секунду. Если не указано, используется лимит VK для токена сообщества ([api.LimitGroupToken]).
+	Rate float64
+	// Число запросов, которые можно сделать подряд без ожидания. Если не указано, равно Rate.
+	Burst int
+	// Число повторов при ошибках лимитов. Если не указано, 3; отрицательное значение отключает повторы.
+	MaxRetries int
+	// Начальная задержка перед повтором, удваивается с каждой попыткой. Если не указана, 1 секунда.
+	RetryDelay time.Duration
+	// Объединять сообщения в разные беседы в один запрос execute.
+	Batch bool
+	// Вместимость очереди. Если очередь заполнена, Send ждет освобождения места. Если не указана, 1000.
+	QueueSize int
+
+	once     sync.Once
+	stopOnce sync.Once
+	mu       sync.Mutex
+	queue    chan *queueItem
+	closed   bool
+	pending  sync.WaitGroup
+	stop     chan struct{}
+	stopped  chan struct{}
+	bucket   *tokenBucket
+	stats    QueueStats
+	// число сообщений, взятых обработчиком из канала и ожидающих повтора или своей очереди
+	deferred int
+	// отменяется, если Close прерван контекстом
+	closeCtx    context.Context
+	closeCancel context.CancelFunc
+}
+
+func (queue *QueueSender) start() {
+	queue.once.Do(func() {
+		queue.init()
+		go newQueueWorker(queue).run()
+	})
+}
+
+func (queue *QueueSender) init() {
+	size := queue.QueueSize
+	if size <= 0 {
+		size = 1000
+	}
+	rate := queue.Rate
+	if rate <= 0 {
+		rate = api.LimitGroupToken
+	}
+	burst := queue.Burst
+	if burst <= 0 {
+		burst = max(1, int(rate))
+	}
+	queue.queue = make(chan *queueItem, size)
+	queue.stop = make(chan struct{})
+	queue.stopped = make(chan struct{})
+	queue.bucket = newTokenBucket(rate, burst)
+	queue.closeCtx, queue.closeCancel = context.WithCancel(context.Background())
+}
+
+// Постановка сообщения в очередь и ожидание его отправки.
+func (queue *QueueSender) Send(ctx context.Context, msg OutgoingMessage) (SentMessage, error) {
+	return queue.enqueue(ctx, &queueItem{msg: msg})
+}
+
+// Изменение отправленного сообщения через очередь, с тем же ограничением частоты и повторами, что и отправка.
+// Если Sender не реализует [MessageEditor], возвращается [errors.ErrUnsupported].
+func (queue *QueueSender) Edit(ctx context.Context, sent SentMessage, msg OutgoingMessage) error {
+	editor, ok := queue.Sender.(MessageEditor)
+	if !ok {
+		return fmt.Errorf("edit error: %w", errors.ErrUnsupported)
+	}
+	return queue.do(ctx, sent.PeerID, func(ctx context.Context) error {
+		return editor.Edit(ctx, sent, msg)
+	})
+}
+
+// Удаление сообщений через очередь. Если Sender не реализует [MessageDeleter], возвращается [errors.ErrUnsupported].
+func (queue *QueueSender) Delete(ctx context.Context, sent SentMessages) error {
+	deleter, ok := queue.Sender.(MessageDeleter)
+	if !ok {
+		return fmt.Errorf("delete error: %w", errors.ErrUnsupported)
+	}
+	if len(sent) == 0 {
+		return nil
+	}
+	return queue.do(ctx, sent[0].PeerID, func(ctx context.Context) error {
+		return deleter.Delete(ctx, sent)
+	})
+}
+
+// Закрепление сообщения через очередь. Если Sender не реализует [MessagePinner], возвращается [errors.ErrUnsupported].
+func (queue *QueueSender) Pin(ctx context.Context, sent SentMessage) error {
+	pinner, ok := queue.Sender.(MessagePinner)
+	if !ok {
+		return fmt.Errorf("pin error: %w", errors.ErrUnsupported)
+	}
+	return queue.do(ctx, sent.PeerID, func(ctx context.Context) error {
+		return pinner.Pin(ctx, sent)
+	})
+}
+
+// Реакция на сообщение через очередь. Если Sender не реализует [MessageReactor], возвращается [errors.ErrUnsupported].
+func (queue *QueueSender) React(ctx context.Context, sent SentMessage, reactionID int) error {
+	reactor, ok := queue.Sender.(MessageReactor)
+	if !ok {
+		return fmt.Errorf("reaction error: %w", errors.ErrUnsupported)
+	}
+	return queue.do(ctx, sent.PeerID, func(ctx context.Context) error {
+		return reactor.React(ctx, sent, reactionID)
+	})
+}
+
+// Выполнение действия с сообщением в беседе peerID в общей очереди.
+func (queue *QueueSender) do(ctx context.Context, peerID int, action func(ctx context.Context) error) error {
+	_, err := queue.enqueue(ctx, &queueItem{action: action, peer: peerID})
+	return err
+}
+
+func (queue *QueueSender) enqueue(ctx context.Context, item *queueItem) (SentMessage, error) {
+	queue.start()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	queue.mu.Lock()
+	if queue.closed {
+		queue.mu.Unlock()
+		return SentMessage{}, ErrSenderClosed
+	}
+	queue.pending.Add(1)
+	queue.mu.Unlock()
+
+	item.ctx = ctx
+	item.done = make(chan queueResult, 1)
+	select {
+	case queue.queue <- item:
+		queue.mu.Lock()
+		queue.stats.MaxDepth = max(queue.stats.MaxDepth, len(queue.queue)+queue.deferred)
+		queue.mu.Unlock()
+	case <-ctx.Done():
+		queue.pending.Done()
+		return SentMessage{}, ctx.Err()
+	}
+
+	select {
+	case result := <-item.done:
+		return result.sent, result.err
+	case <-ctx.Done():
+		// обработчик очереди пропустит отмененное сообщение, если еще не начал его отправку
+		item.canceled.Store(true)
+		return SentMessage{}, ctx.Err()
+	}
+}
+
+// Статистика очереди.
+func (queue *QueueSender) Stats() QueueStats {
+	queue.start()
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	stats := queue.stats
+	stats.Depth = len(queue.queue) + queue.deferred
+	return stats
+}
+
+// Остановка очереди: новые сообщения больше не принимаются ([ErrSenderClosed]), а уже поставленные отправляются.
+// Ждет отправки всех сообщений или отмены контекста. При отмене контекста текущая отправка прерывается, а оставшиеся
+// сообщения (в том числе ожидающие повтора) завершаются с ошибкой контекста. Повторный вызов безопасен и снова ждет
+// оставшиеся сообщения.
+func (queue *QueueSender) Close(ctx context.Context) error {
+	queue.start()
+	queue.mu.Lock()
+	queue.closed = true
+	queue.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		queue.pending.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		queue.closeCancel()
+		return ctx.Err()
+	}
+	queue.stopOnce.Do(func() {
+		close(queue.stop)
+		queue.closeCancel()
+	})
+	<-queue.stopped
+	return nil
+}
+
+func (queue *QueueSender) finish(item *queueItem, sent SentMessage, err error) {
+	queue.mu.Lock()
+	if err != nil {
+		queue.stats.Failed++
+	} else {
+		queue.stats.Sent++
+	}
+	queue.mu.Unlock()
+	item.done <- queueResult{sent: sent, err: err}
+	queue.pending.Done()
+}
+
+func (queue *QueueSender) setDeferred(n int) {
+	queue.mu.Lock()
+	queue.deferred = n
+	queue.mu.Unlock()
+}
+
+func (queue *QueueSender) retryDelay(attempt int) time.Duration {
+	delay := queue.RetryDelay
+	if delay <= 0 {
+		delay = time.Second
+	}
+	return delay << attempt
+}
+
+func (queue *QueueSender) maxRetries() int {
+	if queue.MaxRetries == 0 {
+		return 3
+	}
+	return max(0, queue.MaxRetries)
+}
+
+// Контекст отправки одного сообщения: отменяется вместе с контекстом сообщения или при прерывании Close.
+func (queue *QueueSender) itemContext(item *queueItem) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(item.ctx)
+	if queue.closeCtx.Err() != nil {
+		// AfterFunc для завершенного контекста срабатывает асинхронно
+		cancel()
+		return ctx, cancel
+	}
+	stop := context.AfterFunc(queue.closeCtx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// Контекст отправки пачки: отменяется, когда отменены контексты всех сообщений пачки или прерван Close.
+func (queue *QueueSender) batchContext(items []*queueItem) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(queue.closeCtx)
+	var live atomic.Int32
+	live.Store(int32(len(items)))
+	stops := make([]func() bool, len(items))
+	for i, item := range items {
+		stops[i] = context.AfterFunc(item.ctx, func() {
+			if live.Add(-1) == 0 {
+				cancel()
+			}
+		})
+	}
+	return ctx, func() {
+		for _, stop := range stops {
+			stop()
+		}
+		cancel()
+	}
+}
+
+// Отправка нового сообщения или действие с отправленным.
+func (queue *QueueSender) deliver(ctx context.Context, item *queueItem) (SentMessage, error) {
+	if item.action != nil {
+		return SentMessage{}, item.action(ctx)
+	}
+	return queue.Sender.Send(ctx, item.msg)
+}
+
+// Обработчик очереди. Его состояние меняется только в горутине run, поэтому не защищено мьютексом.
+//
+// Сообщение, ожидающее повтора, не занимает обработчик: оно откладывается до времени повтора, а следующие
+// сообщения в ту же беседу придерживаются до его отправки, чтобы не нарушить порядок.
+type queueWorker struct {
+	queue *QueueSender
+	// nil, если сообщения отправляются по одному
+	batcher BatchSender
+	// сообщения, взятые из канала (или отпущенные после повтора) и еще не отправленные
+	ready []*queueItem
+	// сообщения, ожидающие повтора
+	retries []*queueItem
+	// придержанные сообщения по беседам; ключ есть, пока в беседе ждет повтора сообщение
+	held map[int][]*queueItem
+}
+
+func newQueueWorker(queue *QueueSender) *queueWorker {
+	worker := &queueWorker{queue: queue, held: map[int][]*queueItem{}}
+	if batcher, ok := queue.Sender.(BatchSender); ok && queue.Batch {
+		worker.batcher = batcher
+	}
+	return worker
+}
+
+func (worker *queueWorker) run() {
+	defer close(worker.queue.stopped)
+	for {
+		item, ok := worker.next()
+		if !ok {
+			return
+		}
+		switch {
+		case worker.hold(item):
+		case worker.batcher == nil || item.action != nil || item.attempt > 0:
+			// повторы отправляются по одному
+			worker.sendOne(item)
+		default:
+			if items := worker.collectBatch(item); len(items) == 1 {
+				worker.sendOne(item)
+			} else {
+				worker.sendBatch(items)
+			}
+		}
+		worker.queue.setDeferred(worker.deferred())
+	}
+}
+
+// Следующее сообщение для отправки: сначала наступившие повторы, затем отложенные сообщения, затем канал очереди.
+// Возвращает false после остановки очереди.
+func (worker *queueWorker) next() (*queueItem, bool) {
+	for {
+		now := time.Now()
+		aborted := worker.queue.closeCtx.Err() != nil
+		wait := time.Duration(-1)
+		for i, item := range worker.retries {
+			// при прерывании Close повторы не ждут: они сразу завершатся с ошибкой контекста
+			if aborted || !item.due.After(now) {
+				worker.retries = slices.Delete(worker.retries, i, i+1)
+				return item, true
+			}
+			if d := item.due.Sub(now); wait < 0 || d < wait {
+				wait = d
+			}
+		}
+		if len(worker.ready) > 0 {
+			item := worker.ready[0]
+			worker.ready = worker.ready[1:]
+			return item, true
+		}
+
+		var due <-chan time.Time
+		var closing <-chan struct{}
+		timer := time.NewTimer(wait)
+		if wait >= 0 {
+			due = timer.C
+			closing = worker.queue.closeCtx.Done()
+		}
+		select {
+		case item := <-worker.queue.queue:
+			timer.Stop()
+			return item, true
+		case <-due:
+		case <-closing:
+		case <-worker.queue.stop:
+			timer.Stop()
+			return nil, false
+		}
+		timer.Stop()
+	}
+}
+
+// Следующее сообщение без ожидания: из отложенных или из канала.
+func (worker *queueWorker) poll() (*queueItem, bool) {
+	if len(worker.ready) > 0 {
+		item := worker.ready[0]
+		worker.ready = worker.ready[1:]
+		return item, true
+	}
+	select {
+	case item := <-worker.queue.queue:
+		return item, true
+	default:
+		return nil, false
+	}
+}
+
+// Придерживает сообщение, если в его беседе ждет повтора предыдущее.
+func (worker *queueWorker) hold(item *queueItem) bool {
+	if item.attempt > 0 {
+		return false
+	}
+	peerID := item.peerID()
+	if _, ok := worker.held[peerID]; !ok {
+		return false
+	}
+	worker.held[peerID] = append(worker.held[peerID], item)
+	return true
+}
+
+// Возвращает придержанные сообщения беседы в начало очереди после завершения повтора.
+func (worker *queueWorker) release(peerID int) {
+	worker.ready = append(worker.held[peerID], worker.ready...)
+	delete(worker.held, peerID)
+}
+
+func (worker *queueWorker) deferred() int {
+	n := len(worker.ready) + len(worker.retries)
+	for _, items := range worker.held {
+		n += len(items)
+	}
+	return n
+}
+
+// Добор сообщений для execute. В пачку попадает не больше одного сообщения на беседу, чтобы не нарушить
+// порядок при частичных ошибках; первое сообщение в уже занятую беседу (или действие с сообщением) возвращается
+// в начало очереди и отправляется следующим.
+func (worker *queueWorker) collectBatch(first *queueItem) []*queueItem {
+	items := []*queueItem{first}
+	peers := map[int]bool{first.peerID(): true}
+	for len(items) < MaxExecuteCalls {
+		item, ok := worker.poll()
+		if !ok {
+			break
+		}
+		if worker.hold(item) {
+			continue
+		}
+		if peers[item.peerID()] || item.action != nil {
+			worker.ready = append([]*queueItem{item}, worker.ready...)
+			break
+		}
+		peers[item.peerID()] = true
+		items = append(items, item)
+	}
+	return items
+}
+
+func (worker *queueWorker) skipCanceled(item *queueItem) bool {
+	if !item.canceled.Load() && item.ctx.Err() == nil {
+		return false
+	}
+	err := item.ctx.Err()
+	if err == nil {
+		err = context.Canceled
+	}
+	worker.complete(item, SentMessage{}, err)
+	return true
+}
+
+// Завершение попытки: временная ошибка откладывает сообщение до повтора, иначе результат возвращается отправителю.
+func (worker *queueWorker) complete(item *queueItem, sent SentMessage, err error) {
+	queue := worker.queue
+	peerID := item.peerID()
+	if err != nil && isRetryableSendError(err) && item.attempt < queue.maxRetries() {
+		item.due = time.Now().Add(queue.retryDelay(item.attempt))
+		item.attempt++
+		worker.retries = append(worker.retries, item)
+		if _, ok := worker.held[peerID]; !ok {
+			worker.held[peerID] = nil
+		}
+		queue.mu.Lock()
+		queue.stats.Retried++
+		queue.mu.Unlock()
+		return
+	}
+	queue.finish(item, sent, err)
+	if item.attempt > 0 {
+		worker.release(peerID)
+	}
+}
+
+func (worker *queueWorker) sendOne(item *queueItem) {
+	if worker.skipCanceled(item) {
+		return
+	}
+	ctx, cancel := worker.queue.itemContext(item)
+	defer cancel()
+	if err := worker.queue.bucket.wait(ctx); err != nil {
+		worker.complete(item, SentMessage{}, err)
+		return
+	}
+	sent, err := worker.queue.deliver(ctx, item)
+	worker.complete(item, sent, err)
+}
+
+func (worker *queueWorker) sendBatch(items []*queueItem) {
+	active := items[:0:0]
+	for _, item := range items {
+		if !worker.skipCanceled(item) {
+			active = append(active, item)
+		}
+	}
+	if len(active) <= 1 {
+		for _, item := range active {
+			worker.sendOne(item)
+		}
+		return
+	}
+
+	ctx, cancel := worker.queue.batchContext(active)
+	defer cancel()
+	if err := worker.queue.bucket.wait(ctx); err != nil {
+		for _, item := range active {
+			worker.complete(item, SentMessage{}, err)
+		}
+		return
+	}
+	msgs := make([]OutgoingMessage, len(active))
+	for i, item := range active {
+		msgs[i] = item.msg
+	}
+	results, errs := worker.batcher.SendBatch(ctx, msgs)
+	queue := worker.queue
+	queue.mu.Lock()
+	queue.stats.Batches++
+	queue.mu.Unlock()
+
+	// пачка считается первой попыткой; временные ошибки откладывают сообщение до повтора по одному
+	for i, item := range active {
+		worker.complete(item, results[i], errs[i])
+	}
+}
@@ -0,0 +1,241 @@
+package vkc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SevereCloud/vksdk/v3/api"
+)
+
+type flakySender struct {
+	mu       sync.Mutex
+	failures int
+	sent     []OutgoingMessage
+}
+
+func (s *flakySender) Send(ctx context.Context, msg OutgoingMessage) (SentMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return SentMessage{}, &api.Error{Code: api.ErrTooMany, Message: "Too many requests per second"}
+	}
+	s.sent = append(s.sent, msg)
+	return SentMessage{PeerID: msg.PeerID, MessageID: len(s.sent)}, nil
+}
+
+func TestQueueSenderRetriesAndOrder(t *testing.T) {
+	sender := &flakySender{failures: 2}
+	queue := &QueueSender{Sender: sender, Rate: 1000, RetryDelay: time.Millisecond}
+
+	for i, text := range []string{"один", "два", "три"} {
+		sent, err := queue.Send(context.Background(), OutgoingMessage{PeerID: 1, Text: text})
+		if err != nil {
+			t.Fatalf("Send(%q) error = %v", text, err)
+		}
+		if sent.MessageID != i+1 {
+			t.Errorf("Send(%q) MessageID = %d, want %d", text, sent.MessageID, i+1)
+		}
+	}
+
+	stats := queue.Stats()
+	if stats.Sent != 3 || stats.Retried != 2 || stats.Failed != 0 {
+		t.Errorf("Stats() = %+v, want 3 sent and 2 retried", stats)
+	}
+	if err := queue.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := queue.Send(context.Background(), OutgoingMessage{PeerID: 1}); !errors.Is(err, ErrSenderClosed) {
+		t.Errorf("Send() after Close() error = %v, want %v", err, ErrSenderClosed)
+	}
+}
+
+func TestQueueSenderGivesUp(t *testing.T) {
+	sender := &flakySender{failures: 10}
+	queue := &QueueSender{Sender: sender, Rate: 1000, RetryDelay: time.Millisecond, MaxRetries: 2}
+	defer queue.Close(context.Background())
+
+	_, err := queue.Send(context.Background(), OutgoingMessage{PeerID: 1})
+	if !errors.Is(err, api.ErrTooMany) {
+		t.Errorf("Send() error = %v, want %v", err, api.ErrTooMany)
+	}
+	if stats := queue.Stats(); stats.Retried != 2 || stats.Failed != 1 {
+		t.Errorf("Stats() = %+v, want 2 retried and 1 failed", stats)
+	}
+}
+
+// Отправитель с пакетной отправкой, у которого сообщения в беседу fail всегда упираются в лимит.
+type batchFlakySender struct {
+	mu       sync.Mutex
+	fail     int
+	attempts map[int]int
+	batches  int
+}
+
+func (s *batchFlakySender) Send(ctx context.Context, msg OutgoingMessage) (SentMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts[msg.PeerID]++
+	if msg.PeerID == s.fail {
+		return SentMessage{}, &api.Error{Code: api.ErrTooMany, Message: "Too many requests per second"}
+	}
+	return SentMessage{PeerID: msg.PeerID, MessageID: s.attempts[msg.PeerID]}, nil
+}
+
+func (s *batchFlakySender) SendBatch(ctx context.Context, msgs []OutgoingMessage) ([]SentMessage, []error) {
+	s.mu.Lock()
+	s.batches++
+	s.mu.Unlock()
+	sent := make([]SentMessage, len(msgs))
+	errs := make([]error, len(msgs))
+	for i, msg := range msgs {
+		sent[i], errs[i] = s.Send(ctx, msg)
+	}
+	return sent, errs
+}
+
+func TestQueueSenderBatchRetries(t *testing.T) {
+	sender := &batchFlakySender{fail: 2, attempts: map[int]int{}}
+	queue := &QueueSender{Sender: sender, Rate: 1000, RetryDelay: time.Millisecond, MaxRetries: 2, Batch: true}
+	queue.once.Do(queue.init)
+	defer queue.Close(context.Background())
+
+	// сообщения кладутся в очередь до запуска обработчика, чтобы они гарантированно попали в одну пачку
+	var items []*queueItem
+	for peerID := 1; peerID <= 3; peerID++ {
+		queue.pending.Add(1)
+		item := &queueItem{ctx: context.Background(), msg: OutgoingMessage{PeerID: peerID}, done: make(chan queueResult, 1)}
+		items = append(items, item)
+		queue.queue <- item
+	}
+	go newQueueWorker(queue).run()
+
+	for i, item := range items {
+		result := <-item.done
+		if wantErr := i == 1; (result.err != nil) != wantErr {
+			t.Errorf("message to peer %d error = %v", item.msg.PeerID, result.err)
+		}
+	}
+	// пачка считается первой попыткой: всего MaxRetries+1 попыток
+	if sender.batches != 1 || sender.attempts[2] != 3 || sender.attempts[1] != 1 {
+		t.Errorf("batches = %d, attempts = %v, want 1 batch and 3 attempts for peer 2", sender.batches, sender.attempts)
+	}
+	if stats := queue.Stats(); stats.Batches != 1 || stats.Retried != 2 || stats.Sent != 2 || stats.Failed != 1 {
+		t.Errorf("Stats() = %+v, want 1 batch, 2 retried, 2 sent, 1 failed", stats)
+	}
+}
+
+func TestQueueSenderRetryDoesNotBlockOtherPeers(t *testing.T) {
+	sender := &batchFlakySender{fail: 1, attempts: map[int]int{}}
+	queue := &QueueSender{Sender: sender, Rate: 1000, RetryDelay: time.Hour, MaxRetries: 1}
+
+	failed := make(chan error, 1)
+	go func() {
+		_, err := queue.Send(context.Background(), OutgoingMessage{PeerID: 1})
+		failed <- err
+	}()
+	waitFor(t, func() bool { return queue.Stats().Retried == 1 })
+
+	// сообщение в другую беседу не ждет повтора
+	if _, err := queue.Send(context.Background(), OutgoingMessage{PeerID: 2}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	// следующее сообщение в ту же беседу придерживается до повтора
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := queue.Send(ctx, OutgoingMessage{PeerID: 1}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Send() to retrying peer error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if stats := queue.Stats(); stats.Depth != 2 {
+		t.Errorf("Stats().Depth = %d, want 2", stats.Depth)
+	}
+
+	// прерванный Close не ждет задержки повтора
+	closeCtx, closeCancel := context.WithCancel(context.Background())
+	closeCancel()
+	queue.Close(closeCtx)
+	if err := <-failed; !errors.Is(err, context.Canceled) {
+		t.Errorf("Send() of retried message error = %v, want %v", err, context.Canceled)
+	}
+	if err := queue.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	if sender.attempts[1] != 1 || sender.attempts[2] != 1 {
+		t.Errorf("attempts = %v, want one attempt per peer", sender.attempts)
+	}
+}
+
+func TestQueueSenderCanceledError(t *testing.T) {
+	queue := &QueueSender{}
+	worker := newQueueWorker(queue)
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	item := &queueItem{ctx: ctx, done: make(chan queueResult, 1)}
+	queue.pending.Add(1)
+
+	if !worker.skipCanceled(item) {
+		t.Fatal("skipCanceled() = false, want true")
+	}
+	if result := <-item.done; !errors.Is(result.err, context.DeadlineExceeded) {
+		t.Errorf("result error = %v, want %v", result.err, context.DeadlineExceeded)
+	}
+}
+
+func TestQueueSenderCloseTwice(t *testing.T) {
+	queue := &QueueSender{Sender: &flakySender{}}
+	for range 2 {
+		if err := queue.Close(context.Background()); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+	}
+}
+
+func TestQueueSenderCollectBatch(t *testing.T) {
+	queue := &QueueSender{queue: make(chan *queueItem, 10)}
+	worker := newQueueWorker(queue)
+	item := func(peerID int) *queueItem {
+		return &queueItem{ctx: context.Background(), msg: OutgoingMessage{PeerID: peerID}}
+	}
+	first := item(1)
+	queue.queue <- item(2)
+	queue.queue <- item(3)
+	queue.queue <- item(2)
+	queue.queue <- item(4)
+
+	items := worker.collectBatch(first)
+	if len(items) != 3 || items[1].msg.PeerID != 2 || items[2].msg.PeerID != 3 {
+		t.Errorf("collectBatch() items = %d, want peers 1, 2, 3", len(items))
+	}
+	if len(worker.ready) != 1 || worker.ready[0].msg.PeerID != 2 {
+		t.Errorf("collectBatch() ready = %v, want message to peer 2", worker.ready)
+	}
+	if len(queue.queue) != 1 {
+		t.Errorf("queue length = %d, want 1", len(queue.queue))
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	bucket := newTokenBucket(100, 1)
+	start := time.Now()
+	for range 3 {
+		if err := bucket.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("3 requests at 100 rps took %v, want at least 20ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bucket = newTokenBucket(0.001, 1)
+	bucket.wait(ctx)
+	if err := bucket.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("wait() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,223 @@
+package vkc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/SevereCloud/vksdk/v3/api"
+	"github.com/SevereCloud/vksdk/v3/api/params"
+)
+
+// Отправленное сообщение. Позволяет позднее изменить, удалить или закрепить его.
+//
+// Методы доступны только для сообщений, полученных из методов отправки [CommandContext] (или после вызова [SentMessage.WithVK]).
+// Действия выполняются через [Commands.Sender], если он их поддерживает ([MessageEditor], [MessageDeleter], [MessagePinner],
+// [MessageReactor] — как [QueueSender]), поэтому подчиняются тем же ограничениям частоты; иначе — напрямую через VK API.
+type SentMessage struct {
+	PeerID                int
+	MessageID             int
+	ConversationMessageID int
+
+	vk     *api.VK
+	sender Sender
+}
+
+// Отправитель, умеющий удалять сообщения для всех участников беседы.
+type MessageDeleter interface {
+	Delete(ctx context.Context, sent SentMessages) error
+}
+
+// Отправитель, умеющий закреплять сообщения.
+type MessagePinner interface {
+	Pin(ctx context.Context, sent SentMessage) error
+}
+
+// Отправитель, умеющий ставить реакции на сообщения.
+type MessageReactor interface {
+	React(ctx context.Context, sent SentMessage, reactionID int) error
+}
+
+// Копия сообщения, привязанная к клиенту VK API. Действия с ней выполняются напрямую через VK API, минуя отправитель.
+func (sent SentMessage) WithVK(vk *api.VK) SentMessage {
+	sent.vk = vk
+	sent.sender = nil
+	return sent
+}
+
+func (sent SentMessages) bind(sender Sender, vk *api.VK) SentMessages {
+	for i := range sent {
+		sent[i].sender = sender
+		sent[i].vk = vk
+	}
+	return sent
+}
+
+// Исполнитель действия с сообщением: отправитель сообщения, если он поддерживает действие, иначе [VKSender].
+func sentAction[T any](sent SentMessage) (T, error) {
+	if action, ok := sent.sender.(T); ok {
+		return action, nil
+	}
+	var action T
+	if sent.vk == nil {
+		return action, ErrNoVK
+	}
+	return any(VKSender{VK: sent.vk}).(T), nil
+}
+
+// Изменение текста сообщения. Клавиатура и вложения при этом не сохраняются; чтобы их оставить, используйте [SentMessage.EditMessage].
+func (sent SentMessage) Edit(text string) error {
+	return sent.EditMessage(OutgoingMessage{Text: text})
+}
+
+// Изменение сообщения: текста, вложений и клавиатуры. Поля PeerID, ReplyTo и RandomID игнорируются.
+func (sent SentMessage) EditMessage(msg OutgoingMessage) error {
+	editor, err := sentAction[MessageEditor](sent)
+	if err != nil {
+		return err
+	}
+	return editor.Edit(context.Background(), sent, msg)
+}
+
+// Параметры messages.edit для изменения сообщения на msg.
+func (sent SentMessage) editParams(msg OutgoingMessage) api.Params {
+	b := params.NewMessagesEditBuilder()
+	b.PeerID(sent.PeerID)
+	b.ConversationMessageID(sent.ConversationMessageID)
+	b.Message(msg.Text)
+	b.KeepForwardMessages(true)
+	if len(msg.Attachments) > 0 {
+		b.Attachment(strings.Join(msg.Attachments, ","))
+	}
+	if msg.Keyboard != nil {
+		b.Keyboard(msg.Keyboard)
+	}
+	if msg.Format != nil {
+		b.Params["format_data"] = msg.Format
+	}
+	return b.Params
+}
+
+// Удаление сообщения для всех участников беседы.
+func (sent SentMessage) Delete() error {
+	return SentMessages{sent}.Delete()
+}
+
+// Удаление всех частей сообщения для всех участников беседы.
+func (sent SentMessages) Delete() error {
+	if len(sent) == 0 {
+		return nil
+	}
+	deleter, err := sentAction[MessageDeleter](sent[0])
+	if err != nil {
+		return err
+	}
+	return deleter.Delete(context.Background(), sent)
+}
+
+// Закрепление сообщения в беседе. Бот должен быть администратором беседы.
+func (sent SentMessage) Pin() error {
+	pinner, err := sentAction[MessagePinner](sent)
+	if err != nil {
+		return err
+	}
+	return pinner.Pin(context.Background(), sent)
+}
+
+// Реакция на сообщение (метод messages.sendReaction). ID реакций перечислены в документации VK (от 1 до 16).
+func (sent SentMessage) React(reactionID int) error {
+	reactor, err := sentAction[MessageReactor](sent)
+	if err != nil {
+		return err
+	}
+	return reactor.React(context.Background(), sent, reactionID)
+}
+
+// Удаление сообщений через messages.delete. Все сообщения должны быть из беседы первого.
+func (sender VKSender) Delete(ctx context.Context, sent SentMessages) error {
+	if len(sent) == 0 {
+		return nil
+	}
+	if sender.VK == nil {
+		return fmt.Errorf("delete error: %w", ErrNoVK)
+	}
+	ids := make([]int, len(sent))
+	for i, msg := range sent {
+		ids[i] = msg.ConversationMessageID
+	}
+	b := params.NewMessagesDeleteBuilder()
+	b.PeerID(sent[0].PeerID)
+	// VK SDK передает устаревший параметр conversation_message_ids, актуальное название — cmids
+	b.Params["cmids"] = ids
+	b.DeleteForAll(true)
+	if ctx != nil {
+		b.WithContext(ctx)
+	}
+	if _, err := sender.VK.MessagesDelete(b.Params); err != nil {
+		return fmt.Errorf("delete error: %w", err)
+	}
+	return nil
+}
+
+// Закрепление сообщения через messages.pin.
+func (sender VKSender) Pin(ctx context.Context, sent SentMessage) error {
+	if sender.VK == nil {
+		return fmt.Errorf("pin error: %w", ErrNoVK)
+	}
+	b := params.NewMessagesPinBuilder()
+	b.PeerID(sent.PeerID)
+	b.ConversationMessageID(sent.ConversationMessageID)
+	if ctx != nil {
+		b.WithContext(ctx)
+	}
+	if _, err := sender.VK.MessagesPin(b.Params); err != nil {
+		return fmt.Errorf("pin error: %w", err)
+	}
+	return nil
+}
+
+// Реакция на сообщение через messages.sendReaction.
+func (sender VKSender) React(ctx context.Context, sent SentMessage, reactionID int) error {
+	if sender.VK == nil {
+		return fmt.Errorf("reaction error: %w", ErrNoVK)
+	}
+	p := api.Params{
+		"peer_id":     sent.PeerID,
+		"cmid":        sent.ConversationMessageID,
+		"reaction_id": reactionID,
+	}
+	if ctx != nil {
+		p.WithContext(ctx)
+	}
+	var resp int
+	if err := sender.VK.RequestUnmarshal("messages.sendReaction", &resp, p); err != nil {
+		return fmt.Errorf("reaction error: %w", err)
+	}
+	return nil
+}
+
+// Сообщение, которым была вызвана команда, привязанное к отправителю команды.
+func (ctx CommandContext[DEPS]) invocation() SentMessage {
+	sent := SentMessage{PeerID: ctx.Message.PeerID, ConversationMessageID: ctx.Message.ConversationMessageID}
+	return SentMessages{sent}.bind(ctx.sender(), ctx.VK)[0]
+}
+
+// Удаление сообщения, которым была вызвана команда. Работает только в беседах, где бот является администратором.
+func (ctx CommandContext[DEPS]) DeleteInvocation() error {
+	sent := ctx.invocation()
+	deleter, err := sentAction[MessageDeleter](sent)
+	if err != nil {
+		return err
+	}
+	return deleter.Delete(ctx.Context, SentMessages{sent})
+}
+
+// Реакция на сообщение, которым была вызвана команда.
+func (ctx CommandContext[DEPS]) React(reactionID int) error {
+	sent := ctx.invocation()
+	reactor, err := sentAction[MessageReactor](sent)
+	if err != nil {
+		return err
+	}
+	return reactor.React(ctx.Context, sent, reactionID)
+}
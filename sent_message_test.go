@@ -0,0 +1,172 @@
+package vkc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SevereCloud/vksdk/v3/api"
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+type recordedCall struct {
+	method string
+	params url.Values
+}
+
+// Тестовый VK API, записывающий вызовы. Отвечает объектом на messages.pin и messages.delete, а на остальные методы — значением 1.
+func newRecordingVK(t *testing.T) (*api.VK, func() []recordedCall) {
+	t.Helper()
+	var mu sync.Mutex
+	var calls []recordedCall
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mu.Lock()
+		calls = append(calls, recordedCall{method: strings.TrimPrefix(r.URL.Path, "/"), params: r.PostForm})
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/messages.pin", "/messages.delete":
+			w.Write([]byte(`{"response":{}}`))
+		default:
+			w.Write([]byte(`{"response":1}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	vk := api.NewVK("token")
+	vk.MethodURL = server.URL + "/"
+	return vk, func() []recordedCall {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recordedCall(nil), calls...)
+	}
+}
+
+func TestSentMessageActions(t *testing.T) {
+	vk, calls := newRecordingVK(t)
+	sent := SentMessage{PeerID: 2000000001, ConversationMessageID: 42}.WithVK(vk)
+
+	if err := sent.Edit("Готово"); err != nil {
+		t.Fatalf("Edit() error = %v", err)
+	}
+	if err := sent.Pin(); err != nil {
+		t.Fatalf("Pin() error = %v", err)
+	}
+	if err := sent.React(1); err != nil {
+		t.Fatalf("React() error = %v", err)
+	}
+	if err := (SentMessages{sent, {PeerID: 2000000001, ConversationMessageID: 43, vk: vk}}).Delete(); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	ctx := CommandContext[any]{VK: vk, Message: object.MessagesMessage{PeerID: 2000000001, ConversationMessageID: 40}}
+	if err := ctx.DeleteInvocation(); err != nil {
+		t.Fatalf("DeleteInvocation() error = %v", err)
+	}
+
+	expected := []struct {
+		method string
+		param  string
+		value  string
+	}{
+		{"messages.edit", "message", "Готово"},
+		{"messages.pin", "conversation_message_id", "42"},
+		{"messages.sendReaction", "reaction_id", "1"},
+		{"messages.delete", "cmids", "42,43"},
+		{"messages.delete", "cmids", "40"},
+	}
+	got := calls()
+	if len(got) != len(expected) {
+		t.Fatalf("calls = %d, want %d", len(got), len(expected))
+	}
+	for i, e := range expected {
+		if got[i].method != e.method || got[i].params.Get(e.param) != e.value {
+			t.Errorf("call %d = %s %s=%q, want %s %s=%q", i, got[i].method, e.param, got[i].params.Get(e.param), e.method, e.param, e.value)
+		}
+	}
+}
+
+// Отправитель, записывающий действия с сообщениями.
+type actionSender struct {
+	fakeSender
+	mu      sync.Mutex
+	actions []string
+}
+
+func (s *actionSender) record(action string, sent SentMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actions = append(s.actions, action+":"+strconv.Itoa(sent.ConversationMessageID))
+}
+
+func (s *actionSender) Delete(ctx context.Context, sent SentMessages) error {
+	for _, msg := range sent {
+		s.record("delete", msg)
+	}
+	return nil
+}
+
+func (s *actionSender) Pin(ctx context.Context, sent SentMessage) error {
+	s.record("pin", sent)
+	return nil
+}
+
+func (s *actionSender) React(ctx context.Context, sent SentMessage, reactionID int) error {
+	s.record("react", sent)
+	return nil
+}
+
+func TestSentMessageActionsThroughSender(t *testing.T) {
+	sender := &actionSender{}
+	queue := &QueueSender{Sender: sender, Rate: 1000}
+	defer queue.Close(context.Background())
+	commands := Commands[any]{Sender: queue}
+	ctx := CommandContext[any]{
+		Context:  context.Background(),
+		Message:  object.MessagesMessage{PeerID: 1, ConversationMessageID: 40},
+		commands: &commands,
+	}
+
+	sent, err := ctx.SendMessage(OutgoingMessage{Text: "Загрузка…"})
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if err := sent.Last().Pin(); err != nil {
+		t.Fatalf("Pin() error = %v", err)
+	}
+	if err := sent.Last().React(1); err != nil {
+		t.Fatalf("React() error = %v", err)
+	}
+	if err := sent.Delete(); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := ctx.DeleteInvocation(); err != nil {
+		t.Fatalf("DeleteInvocation() error = %v", err)
+	}
+	// изменение не поддерживается отправителем, поэтому не выполняется в обход очереди
+	if err := sent.Last().Edit("Готово"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Edit() error = %v, want %v", err, errors.ErrUnsupported)
+	}
+
+	want := []string{"pin:1", "react:1", "delete:1", "delete:40"}
+	if !slices.Equal(sender.actions, want) {
+		t.Errorf("actions = %v, want %v", sender.actions, want)
+	}
+}
+
+func TestSentMessageWithoutVK(t *testing.T) {
+	if err := (SentMessage{}).Edit("text"); !errors.Is(err, ErrNoVK) {
+		t.Errorf("Edit() error = %v, want %v", err, ErrNoVK)
+	}
+	if (SentMessages{}).Last() != (SentMessage{}) {
+		t.Error("Last() of empty SentMessages is not empty")
+	}
+}
@@ -0,0 +1,138 @@
+package vkc
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Максимальная длина текста одного сообщения VK. Считается в единицах UTF-16, как и на стороне VK.
+const MaxMessageLength = 4096
+
+var mentionMarkupRe = regexp.MustCompile(`\[(?:id|club|public)\d+\|[^\]\n]*\]`)
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// Наибольший индекс байта, до которого текст укладывается в limit единиц UTF-16. Всегда приходится на границу руны.
+func utf16Cut(s string, limit int) int {
+	n := 0
+	for i, r := range s {
+		n += utf16.RuneLen(r)
+		if n > limit {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// Поиск места разреза: сначала по абзацам, затем по строкам, затем по пробелам. Если ничего не нашлось, режется по границе руны.
+func findSplitPoint(s string, cut int) int {
+	head := s[:cut]
+	for _, sep := range []string{"\n\n", "\n"} {
+		if i := strings.LastIndex(head, sep); i > 0 {
+			return i
+		}
+	}
+	if i := strings.LastIndexFunc(head, unicode.IsSpace); i > 0 {
+		return i
+	}
+	return cut
+}
+
+// Сдвиг места разреза за пределы разметки упоминания ([id1|Имя]), если он попал внутрь нее.
+func avoidMentionSplit(s string, cut int) int {
+	for _, loc := range mentionMarkupRe.FindAllStringIndex(s, -1) {
+		if loc[0] >= cut {
+			break
+		}
+		if cut < loc[1] {
+			if loc[0] > 0 {
+				return loc[0]
+			}
+			return cut
+		}
+	}
+	return cut
+}
+
+// Разбиение длинного текста на части не длиннее limit единиц UTF-16.
+//
+// Текст режется по границам абзацев, строк или слов (в порядке предпочтения), никогда не посреди символа
+// и не внутри разметки упоминаний вида [id1|Имя]. Пробельные символы на месте разреза отбрасываются.
+// Если limit не больше нуля, используется [MaxMessageLength].
+func SplitMessage(text string, limit int) []string {
+	spans := splitMessageSpans(text, limit)
+	parts := make([]string, len(spans))
+	for i, span := range spans {
+		parts[i] = text[span[0]:span[1]]
+	}
+	return parts
+}
+
+// Границы частей текста в байтах (начало и конец каждой части в исходной строке). См. [SplitMessage].
+func splitMessageSpans(text string, limit int) [][2]int {
+	if limit <= 0 {
+		limit = MaxMessageLength
+	}
+	var spans [][2]int
+	start, end := 0, len(text)
+	for utf16Len(text[start:end]) > limit {
+		rest := text[start:end]
+		cut := utf16Cut(rest, limit)
+		if cut == 0 {
+			// лимит меньше одного символа, режем по первой руне
+			_, cut = utf8.DecodeRuneInString(rest)
+		}
+		cut = avoidMentionSplit(rest, findSplitPoint(rest, cut))
+
+		part := strings.TrimRightFunc(rest[:cut], unicode.IsSpace)
+		if part != "" {
+			spans = append(spans, [2]int{start, start + len(part)})
+		}
+		next := strings.TrimLeftFunc(rest[cut:], unicode.IsSpace)
+		start = end - len(next)
+	}
+	if start < end || len(spans) == 0 {
+		spans = append(spans, [2]int{start, end})
+	}
+	return spans
+}
+
+// Отправка сообщения с разбиением длинного текста на части (см. [SplitMessage]).
+//
+// Части отправляются по порядку. Ответ (ReplyTo) прикрепляется к первой части, а вложения и клавиатура — к последней.
+// Разметка (Format) делится между частями: смещения пересчитываются относительно начала каждой части.
+// Возвращает все отправленные сообщения; при ошибке — уже отправленные части и саму ошибку.
+func SendSplit(ctx context.Context, sender Sender, msg OutgoingMessage) (SentMessages, error) {
+	parts := splitMessageSpans(msg.Text, MaxMessageLength)
+	sent := make(SentMessages, 0, len(parts))
+	for i, span := range parts {
+		part := msg
+		part.Text = msg.Text[span[0]:span[1]]
+		if msg.Format != nil && len(parts) > 1 {
+			part.Format = msg.Format.slice(utf16Len(msg.Text[:span[0]]), utf16Len(part.Text))
+		}
+		if i > 0 {
+			part.ReplyTo = 0
+		}
+		if i < len(parts)-1 {
+			part.Attachments = nil
+			part.Keyboard = nil
+		}
+		result, err := sender.Send(ctx, part)
+		if err != nil {
+			return sent, err
+		}
+		sent = append(sent, result)
+	}
+	return sent, nil
+}
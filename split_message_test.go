@@ -0,0 +1,113 @@
+package vkc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		limit    int
+		expected []string
+	}{
+		{
+			name:     "short text",
+			text:     "hello",
+			limit:    10,
+			expected: []string{"hello"},
+		},
+		{
+			name:     "empty text",
+			text:     "",
+			limit:    10,
+			expected: []string{""},
+		},
+		{
+			name:     "paragraphs first",
+			text:     "first line\nsecond\n\nthird",
+			limit:    20,
+			expected: []string{"first line\nsecond", "third"},
+		},
+		{
+			name:     "lines",
+			text:     "first line\nsecond line",
+			limit:    15,
+			expected: []string{"first line", "second line"},
+		},
+		{
+			name:     "words",
+			text:     "one two three four",
+			limit:    9,
+			expected: []string{"one two", "three", "four"},
+		},
+		{
+			name:     "hard split keeps runes",
+			text:     "абвгдеёжзи",
+			limit:    4,
+			expected: []string{"абвг", "деёж", "зи"},
+		},
+		{
+			name:     "surrogate pairs count twice",
+			text:     "😀😀😀",
+			limit:    4,
+			expected: []string{"😀😀", "😀"},
+		},
+		{
+			name:     "mention is not split",
+			text:     "привет[id1|Павел Дуров]",
+			limit:    20,
+			expected: []string{"привет", "[id1|Павел Дуров]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := SplitMessage(tt.text, tt.limit)
+			if len(parts) != len(tt.expected) {
+				t.Fatalf("SplitMessage(%q, %d) = %q, want %q", tt.text, tt.limit, parts, tt.expected)
+			}
+			for i := range parts {
+				if parts[i] != tt.expected[i] {
+					t.Errorf("SplitMessage(%q, %d)[%d] = %q, want %q", tt.text, tt.limit, i, parts[i], tt.expected[i])
+				}
+				if !utf8.ValidString(parts[i]) {
+					t.Errorf("SplitMessage(%q, %d)[%d] is not valid UTF-8", tt.text, tt.limit, i)
+				}
+			}
+		})
+	}
+}
+
+func TestSendSplit(t *testing.T) {
+	sender := &fakeSender{}
+	text := strings.Repeat("слово ", 1000) // 6000 символов
+	sent, err := SendSplit(context.Background(), sender, OutgoingMessage{
+		PeerID:      1,
+		Text:        text,
+		ReplyTo:     7,
+		Attachments: []string{"photo1_1"},
+	})
+	if err != nil {
+		t.Fatalf("SendSplit() error = %v", err)
+	}
+	if len(sent) != 2 || len(sender.sent) != 2 {
+		t.Fatalf("sent %d messages, want 2", len(sender.sent))
+	}
+	first, last := sender.sent[0], sender.sent[1]
+	if first.ReplyTo != 7 || last.ReplyTo != 0 {
+		t.Errorf("ReplyTo = %d, %d, want 7, 0", first.ReplyTo, last.ReplyTo)
+	}
+	if len(first.Attachments) != 0 || len(last.Attachments) != 1 {
+		t.Errorf("attachments = %v, %v, want only on last part", first.Attachments, last.Attachments)
+	}
+	if utf16Len(first.Text) > MaxMessageLength {
+		t.Errorf("first part length = %d, want <= %d", utf16Len(first.Text), MaxMessageLength)
+	}
+	if sent[0].MessageID != 1 || sent[1].MessageID != 2 {
+		t.Errorf("sent = %+v", sent)
+	}
+}
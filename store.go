@@ -0,0 +1,145 @@
+package vkc
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Хранилище "ключ-значение" для состояния ботов: сессий, диалогов, настроек бесед и т.д.
+// Реализация должна быть безопасна для конкурентного использования.
+//
+// Значения хранятся в виде байтов; для структур удобно использовать [GetJSON] и [SetJSON].
+// Нулевой ttl означает хранение без ограничения по времени.
+//
+// В модуле есть две реализации: [NewMemoryStore] (в памяти процесса) и [OpenFileStore] (в локальном файле).
+type Store interface {
+	// Получение значения. Если ключа нет или его время жизни истекло, возвращает false без ошибки.
+	Get(key string) (value []byte, ok bool, err error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+	// Атомарное изменение значения. Функция fn получает текущее значение (или false, если его нет) и возвращает новое.
+	// Если fn возвращает nil, ключ удаляется; если ошибку, значение не меняется, а ошибка возвращается из Update.
+	Update(key string, ttl time.Duration, fn func(value []byte, ok bool) ([]byte, error)) error
+}
+
+type namespacedStore struct {
+	store  Store
+	prefix string
+}
+
+// Пространство имен в хранилище. Все ключи получают префикс "namespace:", поэтому разные подсистемы не пересекаются.
+//
+// Пример использования:
+//
+//	cooldowns := Namespace(store, "cooldown")
+//	cooldowns.Set("123", []byte("1"), time.Minute) // в хранилище будет ключ "cooldown:123"
+func Namespace(store Store, namespace string) Store {
+	return namespacedStore{store: store, prefix: namespace + ":"}
+}
+
+func (ns namespacedStore) Get(key string) ([]byte, bool, error) {
+	return ns.store.Get(ns.prefix + key)
+}
+
+func (ns namespacedStore) Set(key string, value []byte, ttl time.Duration) error {
+	return ns.store.Set(ns.prefix+key, value, ttl)
+}
+
+func (ns namespacedStore) Delete(key string) error {
+	return ns.store.Delete(ns.prefix + key)
+}
+
+func (ns namespacedStore) Update(key string, ttl time.Duration, fn func([]byte, bool) ([]byte, error)) error {
+	return ns.store.Update(ns.prefix+key, ttl, fn)
+}
+
+// Хранилище-заглушка для случаев, когда [Commands.Store] не задан. Все операции возвращают [ErrStoreUnavailable].
+type unavailableStore struct{}
+
+func (unavailableStore) Get(string) ([]byte, bool, error)        { return nil, false, ErrStoreUnavailable }
+func (unavailableStore) Set(string, []byte, time.Duration) error { return ErrStoreUnavailable }
+func (unavailableStore) Delete(string) error                     { return ErrStoreUnavailable }
+func (unavailableStore) Update(string, time.Duration, func([]byte, bool) ([]byte, error)) error {
+	return ErrStoreUnavailable
+}
+
+// Получение значения из хранилища с декодированием из JSON. Возвращает false, если ключа нет.
+func GetJSON[T any](store Store, key string, value *T) (bool, error) {
+	raw, ok, err := store.Get(key)
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, json.Unmarshal(raw, value)
+}
+
+// Сохранение значения в хранилище в виде JSON.
+func SetJSON[T any](store Store, key string, value T, ttl time.Duration) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return store.Set(key, raw, ttl)
+}
+
+func (ctx CommandContext[DEPS]) store() Store {
+	if ctx.commands == nil || ctx.commands.Store == nil {
+		return unavailableStore{}
+	}
+	return ctx.commands.Store
+}
+
+// Сессия автора сообщения в текущей беседе: данные, общие для всех команд этого пользователя в этой беседе.
+//
+// Если в объекте команд не задано хранилище [Commands.Store], все операции сессии возвращают [ErrStoreUnavailable].
+func (ctx CommandContext[DEPS]) Session() Store {
+	return Namespace(ctx.store(), "peer:"+strconv.Itoa(ctx.Message.PeerID)+":user:"+strconv.Itoa(ctx.Message.FromID))
+}
+
+// Сессия автора сообщения, общая для всех бесед.
+func (ctx CommandContext[DEPS]) UserSession() Store {
+	return Namespace(ctx.store(), "user:"+strconv.Itoa(ctx.Message.FromID))
+}
+
+// Сессия текущей беседы, общая для всех ее участников. Подходит для настроек беседы.
+func (ctx CommandContext[DEPS]) PeerSession() Store {
+	return Namespace(ctx.store(), "peer:"+strconv.Itoa(ctx.Message.PeerID))
+}
+
+type storeDialogStore struct {
+	store Store
+}
+
+// Хранилище состояний диалогов поверх [Store]. Состояния хранятся в пространстве имен "dialog" в виде JSON,
+// а время жизни записи равно [Dialog.Timeout].
+//
+//	store, err := OpenFileStore("bot.db")
+//	// ...
+//	commands.Store = store
+//	commands.DialogStore = NewStoreDialogStore(store)
+func NewStoreDialogStore(store Store) DialogStore {
+	return storeDialogStore{store: Namespace(store, "dialog")}
+}
+
+func (key DialogKey) String() string {
+	return strconv.Itoa(key.PeerID) + ":" + strconv.Itoa(key.UserID)
+}
+
+func (ds storeDialogStore) Get(key DialogKey) (*DialogState, error) {
+	var state DialogState
+	ok, err := GetJSON(ds.store, key.String(), &state)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Время жизни записи — [DialogState.Timeout]: ExpiresAt считается по часам объекта команд, и сравнивать его
+// с системными часами нельзя. Истечение диалога проверяется при обработке сообщения по тем же часам, что и ExpiresAt.
+func (ds storeDialogStore) Set(key DialogKey, state *DialogState) error {
+	return SetJSON(ds.store, key.String(), state, state.Timeout)
+}
+
+func (ds storeDialogStore) Delete(key DialogKey) error {
+	return ds.store.Delete(key.String())
+}
@@ -0,0 +1,150 @@
+package vkc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Хранилище в локальном файле. Все данные держатся в памяти, а после каждого изменения файл атомарно перезаписывается
+// (через временный файл и переименование), поэтому данные переживают перезапуск бота.
+// Если записать файл не удалось, изменение отменяется и метод возвращает ошибку.
+//
+// Каждая запись перезаписывает весь файл. При частых изменениях (например, [Deduplicator] или ответы команд
+// с [EditRerunAndEditReply] на каждое сообщение) задайте [FileStore.SaveInterval], чтобы изменения сохранялись пачками.
+//
+// Подходит для небольших ботов с умеренным числом записей; для больших объемов лучше реализовать [Store] поверх базы данных.
+type FileStore struct {
+	MemoryStore
+	// Интервал отложенной записи. Если задан, изменения применяются только в памяти, а файл перезаписывается
+	// не чаще одного раза за интервал и при вызове [FileStore.Flush] или [FileStore.Close]. Изменения за последний
+	// интервал теряются при аварийном завершении, а ошибки записи возвращает только Flush (несохраненные изменения
+	// записываются при следующей попытке). Задается до первого изменения.
+	SaveInterval time.Duration
+
+	path string
+	// упорядочивает отложенные записи файла
+	saveMu sync.Mutex
+	// под MemoryStore.mu: есть несохраненные изменения и таймер отложенной записи
+	dirty bool
+	timer *time.Timer
+}
+
+// Открытие (или создание) файлового хранилища.
+//
+// Записи с истекшим временем жизни не возвращаются и не попадают в файл при следующей записи;
+// время считается по [MemoryStore.Clock].
+func OpenFileStore(path string) (*FileStore, error) {
+	store := &FileStore{
+		MemoryStore: MemoryStore{items: make(map[string]storeItem)},
+		path:        path,
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("open store: %w", err)
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &store.items); err != nil {
+			return nil, fmt.Errorf("open store: %w", err)
+		}
+	}
+
+	store.onChange = store.changed
+	return store, nil
+}
+
+// Вызывается под блокировкой MemoryStore после каждого изменения.
+func (store *FileStore) changed(items map[string]storeItem) error {
+	if store.SaveInterval <= 0 {
+		raw, err := store.encode(items)
+		if err != nil {
+			return err
+		}
+		// записи уже упорядочены блокировкой MemoryStore
+		return store.write(raw)
+	}
+	store.dirty = true
+	if store.timer == nil {
+		store.timer = time.AfterFunc(store.SaveInterval, func() { store.Flush() })
+	}
+	return nil
+}
+
+// Запись несохраненных изменений в файл. Нужна только при [FileStore.SaveInterval].
+func (store *FileStore) Flush() error {
+	store.saveMu.Lock()
+	defer store.saveMu.Unlock()
+
+	store.mu.Lock()
+	if store.timer != nil {
+		store.timer.Stop()
+		store.timer = nil
+	}
+	if !store.dirty {
+		store.mu.Unlock()
+		return nil
+	}
+	raw, err := store.encode(store.items)
+	store.dirty = err != nil
+	store.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := store.write(raw); err != nil {
+		store.mu.Lock()
+		store.dirty = true
+		store.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Запись несохраненных изменений перед завершением работы. Хранилище можно использовать и после Close.
+func (store *FileStore) Close() error {
+	return store.Flush()
+}
+
+func (store *FileStore) encode(items map[string]storeItem) ([]byte, error) {
+	now := store.Clock.now()
+	live := make(map[string]storeItem, len(items))
+	for key, item := range items {
+		if !item.expired(now) {
+			live[key] = item
+		}
+	}
+	raw, err := json.Marshal(live)
+	if err != nil {
+		return nil, fmt.Errorf("save store: %w", err)
+	}
+	return raw, nil
+}
+
+func (store *FileStore) write(raw []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(store.path), filepath.Base(store.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("save store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("save store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), store.path); err != nil {
+		return fmt.Errorf("save store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package vkc
+
+import (
+	"sync"
+	"time"
+)
+
+type storeItem struct {
+	Value     []byte    `json:"value"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+func (item storeItem) expired(now time.Time) bool {
+	return !item.ExpiresAt.IsZero() && !now.Before(item.ExpiresAt)
+}
+
+func newStoreItem(value []byte, ttl time.Duration, now time.Time) storeItem {
+	item := storeItem{Value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		item.ExpiresAt = now.Add(ttl)
+	}
+	return item
+}
+
+// Хранилище в памяти процесса. Данные теряются при перезапуске.
+//
+// Записи с истекшим временем жизни удаляются при обращении к ним и при вызове [MemoryStore.DeleteExpired].
+type MemoryStore struct {
+	// Источник времени для сроков жизни записей. Если не задан, используются системные часы.
+	Clock Clock
+
+	mu    sync.Mutex
+	items map[string]storeItem
+	// вызывается после каждого изменения под блокировкой; используется FileStore.
+	// Если возвращает ошибку, изменение отменяется.
+	onChange func(items map[string]storeItem) error
+}
+
+// Создание хранилища в памяти.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: make(map[string]storeItem)}
+}
+
+// Фиксация изменения: при ошибке onChange изменение отменяется функцией undo, чтобы память не расходилась с файлом.
+func (store *MemoryStore) commit(undo func()) error {
+	if store.onChange == nil {
+		return nil
+	}
+	if err := store.onChange(store.items); err != nil {
+		undo()
+		return err
+	}
+	return nil
+}
+
+// Функция отмены изменения записи key: восстанавливает прежнее значение или удаляет запись, если ее не было.
+func (store *MemoryStore) restore(key string) func() {
+	prev, ok := store.items[key]
+	return func() {
+		if ok {
+			store.items[key] = prev
+		} else {
+			delete(store.items, key)
+		}
+	}
+}
+
+func (store *MemoryStore) Get(key string) ([]byte, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	item, ok := store.items[key]
+	if !ok || item.expired(store.Clock.now()) {
+		return nil, false, nil
+	}
+	return append([]byte(nil), item.Value...), true, nil
+}
+
+func (store *MemoryStore) Set(key string, value []byte, ttl time.Duration) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	undo := store.restore(key)
+	store.items[key] = newStoreItem(value, ttl, store.Clock.now())
+	return store.commit(undo)
+}
+
+func (store *MemoryStore) Delete(key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := store.items[key]; !ok {
+		return nil
+	}
+	undo := store.restore(key)
+	delete(store.items, key)
+	return store.commit(undo)
+}
+
+func (store *MemoryStore) Update(key string, ttl time.Duration, fn func([]byte, bool) ([]byte, error)) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var current []byte
+	item, ok := store.items[key]
+	if ok && item.expired(store.Clock.now()) {
+		ok = false
+	}
+	if ok {
+		current = append([]byte(nil), item.Value...)
+	}
+
+	value, err := fn(current, ok)
+	if err != nil {
+		return err
+	}
+	undo := store.restore(key)
+	if value == nil {
+		if !ok {
+			return nil
+		}
+		delete(store.items, key)
+	} else {
+		store.items[key] = newStoreItem(value, ttl, store.Clock.now())
+	}
+	return store.commit(undo)
+}
+
+// Удаление всех записей с истекшим временем жизни.
+func (store *MemoryStore) DeleteExpired() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	now := store.Clock.now()
+	removed := make(map[string]storeItem)
+	for key, item := range store.items {
+		if item.expired(now) {
+			delete(store.items, key)
+			removed[key] = item
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	return store.commit(func() {
+		for key, item := range removed {
+			store.items[key] = item
+		}
+	})
+}
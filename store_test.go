@@ -0,0 +1,250 @@
+package vkc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SevereCloud/vksdk/v3/object"
+)
+
+func testStore(t *testing.T, store Store) {
+	t.Helper()
+
+	if _, ok, err := store.Get("missing"); ok || err != nil {
+		t.Errorf("Get(missing) = %v, %v, want false, nil", ok, err)
+	}
+
+	if err := store.Set("key", []byte("value"), 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if value, ok, _ := store.Get("key"); !ok || string(value) != "value" {
+		t.Errorf("Get(key) = %q, %v, want %q, true", value, ok, "value")
+	}
+
+	if err := store.Set("short", []byte("value"), time.Nanosecond); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, ok, _ := store.Get("short"); ok {
+		t.Error("Get(short) after ttl ok = true, want false")
+	}
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.Update("counter", 0, func(value []byte, ok bool) ([]byte, error) {
+				n := 0
+				if ok {
+					n, _ = strconv.Atoi(string(value))
+				}
+				return []byte(strconv.Itoa(n + 1)), nil
+			})
+		}()
+	}
+	wg.Wait()
+	if value, _, _ := store.Get("counter"); string(value) != "20" {
+		t.Errorf("Get(counter) = %q, want %q", value, "20")
+	}
+
+	failure := errors.New("failure")
+	if err := store.Update("counter", 0, func([]byte, bool) ([]byte, error) { return nil, failure }); !errors.Is(err, failure) {
+		t.Errorf("Update() error = %v, want %v", err, failure)
+	}
+	if err := store.Update("counter", 0, func([]byte, bool) ([]byte, error) { return nil, nil }); err != nil {
+		t.Errorf("Update() error = %v", err)
+	}
+	if _, ok, _ := store.Get("counter"); ok {
+		t.Error("Get(counter) after nil update ok = true, want false")
+	}
+
+	if err := store.Delete("key"); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if _, ok, _ := store.Get("key"); ok {
+		t.Error("Get(key) after delete ok = true, want false")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.db")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() error = %v", err)
+	}
+	testStore(t, store)
+
+	if err := SetJSON(store, "settings", map[string]string{"prefix": "/"}, 0); err != nil {
+		t.Fatalf("SetJSON() error = %v", err)
+	}
+
+	reopened, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() reopen error = %v", err)
+	}
+	var settings map[string]string
+	if ok, err := GetJSON(reopened, "settings", &settings); !ok || err != nil || settings["prefix"] != "/" {
+		t.Errorf("GetJSON() after reopen = %v, %v, %v, want prefix /", settings, ok, err)
+	}
+}
+
+func TestFileStoreRollback(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenFileStore(filepath.Join(dir, "bot.db"))
+	if err != nil {
+		t.Fatalf("OpenFileStore() error = %v", err)
+	}
+	if err := store.Set("kept", []byte("1"), 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	// файл больше нельзя записать: изменения должны отменяться
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Set("new", []byte("1"), 0); err == nil {
+		t.Error("Set() error = nil, want save error")
+	}
+	if _, ok, _ := store.Get("new"); ok {
+		t.Error("Get(new) after failed Set ok = true, want false")
+	}
+	if err := store.Delete("kept"); err == nil {
+		t.Error("Delete() error = nil, want save error")
+	}
+	if err := store.Update("kept", 0, func([]byte, bool) ([]byte, error) { return []byte("2"), nil }); err == nil {
+		t.Error("Update() error = nil, want save error")
+	}
+	if value, ok, _ := store.Get("kept"); !ok || string(value) != "1" {
+		t.Errorf("Get(kept) after failed changes = %q, %v, want 1, true", value, ok)
+	}
+}
+
+func TestFileStoreSaveInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.db")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() error = %v", err)
+	}
+	store.SaveInterval = time.Hour
+	clock := &ManualClock{}
+	clock.Set(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	store.Clock = clock.Now
+
+	store.Set("key", []byte("value"), 0)
+	store.Set("short", []byte("value"), time.Minute)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("file written before Flush, Stat() error = %v", err)
+	}
+
+	clock.Add(2 * time.Minute)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// запись с истекшим по часам хранилища сроком не сохраняется
+	if strings.Contains(string(raw), "short") || !strings.Contains(string(raw), "key") {
+		t.Errorf("saved file = %s, want only key", raw)
+	}
+}
+
+func TestSessionScopes(t *testing.T) {
+	commands := Commands[any]{Store: NewMemoryStore()}
+	ctx := CommandContext[any]{
+		Message:  object.MessagesMessage{PeerID: 2000000001, FromID: 1},
+		commands: &commands,
+	}
+	other := ctx
+	other.Message.PeerID = 2000000002
+
+	ctx.Session().Set("step", []byte("1"), 0)
+	ctx.UserSession().Set("lang", []byte("ru"), 0)
+	ctx.PeerSession().Set("prefix", []byte("/"), 0)
+
+	if _, ok, _ := other.Session().Get("step"); ok {
+		t.Error("Session() leaked into another peer")
+	}
+	if value, _, _ := other.UserSession().Get("lang"); string(value) != "ru" {
+		t.Errorf("UserSession().Get(lang) = %q, want %q", value, "ru")
+	}
+	if _, ok, _ := other.PeerSession().Get("prefix"); ok {
+		t.Error("PeerSession() leaked into another peer")
+	}
+
+	if _, _, err := (CommandContext[any]{}).Session().Get("step"); !errors.Is(err, ErrStoreUnavailable) {
+		t.Errorf("Session() without store error = %v, want %v", err, ErrStoreUnavailable)
+	}
+}
+
+func TestStoreDialogStore(t *testing.T) {
+	store := NewStoreDialogStore(NewMemoryStore())
+	key := DialogKey{PeerID: 1, UserID: 2}
+
+	if err := store.Set(key, &DialogState{Dialog: "survey", Step: "name", Data: map[string]string{"a": "b"}}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	state, err := store.Get(key)
+	if err != nil || state == nil || state.Step != "name" || state.Data["a"] != "b" {
+		t.Errorf("Get() = %+v, %v", state, err)
+	}
+
+	// время жизни записи считается по часам хранилища от Timeout, а не по ExpiresAt и системным часам
+	clock := &ManualClock{}
+	memory := NewMemoryStore()
+	memory.Clock = clock.Now
+	store = NewStoreDialogStore(memory)
+	store.Set(key, &DialogState{Dialog: "survey", Step: "name", ExpiresAt: time.Time{}.Add(time.Minute), Timeout: time.Minute})
+	if state, _ := store.Get(key); state == nil {
+		t.Error("Get() before timeout = nil, want state")
+	}
+	clock.Add(2 * time.Minute)
+	if state, _ := store.Get(key); state != nil {
+		t.Errorf("Get() expired = %+v, want nil", state)
+	}
+}
+
+func TestStoreDialogStoreManualClock(t *testing.T) {
+	var steps []string
+	commands := newTestDialogCommands(&steps)
+	commands.Dialogs[0].Timeout = time.Minute
+	// часы бота далеко в прошлом, как при воспроизведении старых событий
+	clock := &ManualClock{}
+	clock.Set(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC))
+	commands.Clock = clock.Now
+	memory := NewMemoryStore()
+	memory.Clock = clock.Now
+	commands.DialogStore = NewStoreDialogStore(memory)
+	ctx := context.Background()
+
+	for _, text := range []string{"!survey", "Иван"} {
+		clock.Add(10 * time.Second)
+		if err := commands.ProcessCommands(ctx, nil, newTestMessage(1, 1, text)); err != nil {
+			t.Fatalf("ProcessCommands(%q) error = %v", text, err)
+		}
+	}
+	// ответ после истечения диалога уже не шаг диалога
+	clock.Add(2 * time.Minute)
+	if err := commands.ProcessCommands(ctx, nil, newTestMessage(1, 1, "25")); !errors.Is(err, ErrNoPrefix) {
+		t.Errorf("answer after timeout error = %v, want %v", err, ErrNoPrefix)
+	}
+	if actual := strings.Join(steps, "|"); actual != "start|name:Иван" {
+		t.Errorf("steps = %s, want start|name:Иван", actual)
+	}
+}
@@ -0,0 +1,325 @@
+package vkc
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Значения для подстановки в [Template].
+type Vars map[string]any
+
+// Текст, который подставляется в шаблон без экранирования. Используйте только для разметки, составленной самим ботом.
+type Markup string
+
+type templatePart struct {
+	text string
+	// имя подстановки; пустое для обычного текста
+	name string
+}
+
+// Шаблон сообщения с именованными подстановками вида {name}. Безопасная замена fmt.Sprintf для текста, видимого пользователям.
+//
+// Значения подставляются через fmt.Sprint и экранируются (см. [EscapeMarkup]), поэтому имя пользователя вида
+// "[id1|Павел]" не превратится в упоминание. Для разметки, составленной самим ботом, используйте тип [Markup].
+// Символ "%" в шаблоне не имеет специального значения, а фигурные скобки записываются как "{{" и "}}".
+//
+// Пример использования:
+//
+//	var banned = MustTemplate("Пользователь {name} забанен на {days} дн.", "name", "days")
+//
+//	return ctx.SendTemplate(banned, Vars{"name": ctx.Arguments[0], "days": 7})
+type Template struct {
+	source string
+	parts  []templatePart
+}
+
+// Разбор шаблона. Если переданы имена vars, шаблон может использовать только их:
+// иначе возвращается [ErrTemplateUnknownVar]. Так опечатка в имени подстановки обнаруживается при запуске бота, а не при отправке.
+func ParseTemplate(source string, vars ...string) (*Template, error) {
+	tpl := &Template{source: source}
+	var text strings.Builder
+	for i := 0; i < len(source); i++ {
+		c := source[i]
+		switch {
+		case c == '{' && strings.HasPrefix(source[i:], "{{"), c == '}' && strings.HasPrefix(source[i:], "}}"):
+			text.WriteByte(c)
+			i++
+		case c == '{':
+			end := strings.IndexByte(source[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("template %q: unclosed placeholder at %d: %w", source, i, ErrTemplateSyntax)
+			}
+			name := strings.TrimSpace(source[i+1 : i+end])
+			if name == "" || strings.ContainsAny(name, "{") {
+				return nil, fmt.Errorf("template %q: invalid placeholder at %d: %w", source, i, ErrTemplateSyntax)
+			}
+			if len(vars) > 0 && !slices.Contains(vars, name) {
+				return nil, fmt.Errorf("template %q: {%s}: %w", source, name, ErrTemplateUnknownVar)
+			}
+			if text.Len() > 0 {
+				tpl.parts = append(tpl.parts, templatePart{text: text.String()})
+				text.Reset()
+			}
+			tpl.parts = append(tpl.parts, templatePart{name: name})
+			i += end
+		case c == '}':
+			return nil, fmt.Errorf("template %q: unexpected \"}\" at %d: %w", source, i, ErrTemplateSyntax)
+		default:
+			text.WriteByte(c)
+		}
+	}
+	if text.Len() > 0 {
+		tpl.parts = append(tpl.parts, templatePart{text: text.String()})
+	}
+	return tpl, nil
+}
+
+// То же, что и [ParseTemplate], но паникует при ошибке. Удобно для объявления шаблонов в глобальных переменных.
+func MustTemplate(source string, vars ...string) *Template {
+	tpl, err := ParseTemplate(source, vars...)
+	if err != nil {
+		panic(err)
+	}
+	return tpl
+}
+
+// Исходный текст шаблона.
+func (tpl *Template) String() string {
+	return tpl.source
+}
+
+// Имена подстановок в порядке первого появления.
+func (tpl *Template) Placeholders() []string {
+	var names []string
+	for _, part := range tpl.parts {
+		if part.name != "" && !slices.Contains(names, part.name) {
+			names = append(names, part.name)
+		}
+	}
+	return names
+}
+
+// Подстановка значений. Возвращает [ErrTemplateMissingVar], если для какой-то подстановки не передано значение.
+func (tpl *Template) Render(vars Vars) (string, error) {
+	var out strings.Builder
+	for _, part := range tpl.parts {
+		if part.name == "" {
+			out.WriteString(part.text)
+			continue
+		}
+		value, ok := vars[part.name]
+		if !ok {
+			return "", fmt.Errorf("template %q: {%s}: %w", tpl.source, part.name, ErrTemplateMissingVar)
+		}
+		if markup, ok := value.(Markup); ok {
+			out.WriteString(string(markup))
+		} else {
+			out.WriteString(EscapeMarkup(fmt.Sprint(value)))
+		}
+	}
+	return out.String(), nil
+}
+
+// Отправка сообщения по шаблону.
+func (ctx CommandContext[DEPS]) SendTemplate(tpl *Template, vars Vars) error {
+	text, err := tpl.Render(vars)
+	if err != nil {
+		return err
+	}
+	return ctx.Send(text, nil)
+}
+
+// Отправка ответа на команду по шаблону.
+func (ctx CommandContext[DEPS]) ReplyTemplate(tpl *Template, vars Vars) error {
+	text, err := tpl.Render(vars)
+	if err != nil {
+		return err
+	}
+	return ctx.Send(text, WithReplyParams)
+}
+
+// Проверка соответствия глаголов форматирования аргументам по правилам анализатора printf из go vet: число аргументов,
+// тип аргумента для каждого глагола (%d — целые числа, %s — строки, []byte, error и [fmt.Stringer] и т. д.), аргументы
+// ширины и точности (*) и явные индексы аргументов ([1]). Строка при этом не форматируется, поэтому проверка не зависит от значений аргументов.
+// Предназначена для тестов: позволяет проверить строки, которые передаются в [CommandContext.SendText] и [CommandContext.Reply].
+//
+// Статическую проверку выполняет go vet для методов [CommandContext.Sendf] и [CommandContext.Replyf].
+func CheckFormat(format string, args ...any) error {
+	mismatch := func(problem string, a ...any) error {
+		return fmt.Errorf("format %q: %s: %w", format, fmt.Sprintf(problem, a...), ErrFormatMismatch)
+	}
+	argNum, reordered := 0, false
+	i := 0
+	// явный индекс аргумента: [n]
+	parseIndex := func() error {
+		if i >= len(format) || format[i] != '[' {
+			return nil
+		}
+		end := strings.IndexByte(format[i:], ']')
+		if end < 0 {
+			return mismatch("unclosed argument index")
+		}
+		n, err := strconv.Atoi(format[i+1 : i+end])
+		if err != nil || n < 1 || n > len(args) {
+			return mismatch("bad argument index %s", format[i:i+end+1])
+		}
+		argNum, reordered = n-1, true
+		i += end + 1
+		return nil
+	}
+	// ширина или точность: число или * с аргументом типа int
+	parseNum := func() error {
+		if err := parseIndex(); err != nil {
+			return err
+		}
+		if i < len(format) && format[i] == '*' {
+			i++
+			if argNum >= len(args) {
+				return mismatch("missing argument for *")
+			}
+			if kind := reflect.ValueOf(args[argNum]).Kind(); kind < reflect.Int || kind > reflect.Uintptr {
+				return mismatch("non-int argument %d of type %T for *", argNum+1, args[argNum])
+			}
+			argNum++
+			return nil
+		}
+		for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+			i++
+		}
+		return nil
+	}
+
+	for i < len(format) {
+		if format[i] != '%' {
+			i++
+			continue
+		}
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0 {
+			i++
+		}
+		if err := parseNum(); err != nil {
+			return err
+		}
+		if i < len(format) && format[i] == '.' {
+			i++
+			if err := parseNum(); err != nil {
+				return err
+			}
+		}
+		if err := parseIndex(); err != nil {
+			return err
+		}
+		if i >= len(format) {
+			return mismatch("missing verb at end of format")
+		}
+		verb, size := utf8.DecodeRuneInString(format[i:])
+		i += size
+		if verb == '%' {
+			continue
+		}
+		if argNum >= len(args) {
+			return mismatch("missing argument for %%%c", verb)
+		}
+		if !formatArgMatches(verb, args[argNum]) {
+			return mismatch("%%%c with argument %d of type %T", verb, argNum+1, args[argNum])
+		}
+		argNum++
+	}
+	// fmt не сообщает о лишних аргументах, если использовались явные индексы
+	if !reordered && argNum < len(args) {
+		return mismatch("%d extra arguments", len(args)-argNum)
+	}
+	return nil
+}
+
+var (
+	formatterType = reflect.TypeFor[fmt.Formatter]()
+	stringerType  = reflect.TypeFor[fmt.Stringer]()
+	errorType     = reflect.TypeFor[error]()
+)
+
+// Подходит ли аргумент к глаголу. Аргумент nil выводится только через %v и %T.
+func formatArgMatches(verb rune, arg any) bool {
+	if verb == 'v' || verb == 'T' {
+		return true
+	}
+	if arg == nil {
+		return false
+	}
+	return formatTypeMatches(verb, reflect.TypeOf(arg), nil)
+}
+
+// Проверка типа так же, как в go vet: элементы срезов, массивов и словарей и поля структур проверяются рекурсивно.
+// inProgress — типы, проверка которых уже идет выше по стеку (nil для самого аргумента); для рекурсивных типов.
+func formatTypeMatches(verb rune, t reflect.Type, inProgress map[reflect.Type]bool) bool {
+	if verb == 'v' || t.Implements(formatterType) || inProgress[t] {
+		return true
+	}
+	top := inProgress == nil
+	if top {
+		inProgress = map[reflect.Type]bool{}
+	}
+	inProgress[t] = true
+	defer delete(inProgress, t)
+
+	if (t.Implements(stringerType) || t.Implements(errorType)) && strings.ContainsRune("sqxX", verb) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return verb == 't'
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strings.ContainsRune("bcdoOqxXU", verb)
+	case reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return strings.ContainsRune("bgGeEfFxX", verb)
+	case reflect.String:
+		return strings.ContainsRune("sqxX", verb)
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && verb == 'p' {
+			return true
+		}
+		if t.Elem().Kind() == reflect.Uint8 && strings.ContainsRune("sqxX", verb) {
+			return true
+		}
+		return formatTypeMatches(verb, t.Elem(), inProgress)
+	case reflect.Map:
+		return verb == 'p' || formatTypeMatches(verb, t.Key(), inProgress) && formatTypeMatches(verb, t.Elem(), inProgress)
+	case reflect.Struct:
+		for field := range t.Fields() {
+			if !formatTypeMatches(verb, field.Type, inProgress) {
+				return false
+			}
+		}
+		return true
+	case reflect.Pointer:
+		// указатель на составное значение на верхнем уровне выводится как &{...}
+		if top && verb != 'p' {
+			switch t.Elem().Kind() {
+			case reflect.Struct, reflect.Array, reflect.Slice, reflect.Map:
+				return formatTypeMatches(verb, t.Elem(), inProgress)
+			}
+		}
+		return strings.ContainsRune("pbdoOxX", verb)
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return strings.ContainsRune("pbdoOxX", verb)
+	case reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// Отправка сообщения, отформатированного через fmt.Sprintf. В отличие от [CommandContext.SendText], вызов проверяется go vet.
+func (ctx CommandContext[DEPS]) Sendf(format string, args ...any) error {
+	return ctx.Send(fmt.Sprintf(format, args...), nil)
+}
+
+// Отправка ответа на команду, отформатированного через fmt.Sprintf. В отличие от [CommandContext.Reply], вызов проверяется go vet.
+func (ctx CommandContext[DEPS]) Replyf(format string, args ...any) error {
+	return ctx.Send(fmt.Sprintf(format, args...), WithReplyParams)
+}
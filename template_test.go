@@ -0,0 +1,102 @@
+package vkc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTemplateRender(t *testing.T) {
+	tests := []struct {
+		source   string
+		vars     Vars
+		expected string
+	}{
+		{"Привет, {name}!", Vars{"name": "Иван"}, "Привет, Иван!"},
+		{"Скидка 50% для {name}", Vars{"name": "[id1|Павел]"}, "Скидка 50% для [​id1|Павел]"},
+		{"{mention}, у вас {count} баллов", Vars{"mention": Markup("[id1|Павел]"), "count": 10}, "[id1|Павел], у вас 10 баллов"},
+		{"{{литерал}} {x}", Vars{"x": 1}, "{литерал} 1"},
+	}
+	for _, tt := range tests {
+		tpl, err := ParseTemplate(tt.source)
+		if err != nil {
+			t.Fatalf("ParseTemplate(%q) error = %v", tt.source, err)
+		}
+		actual, err := tpl.Render(tt.vars)
+		if err != nil {
+			t.Fatalf("Render(%q) error = %v", tt.source, err)
+		}
+		if actual != tt.expected {
+			t.Errorf("Render(%q) = %q, want %q", tt.source, actual, tt.expected)
+		}
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		vars   []string
+		err    error
+	}{
+		{"{name", nil, ErrTemplateSyntax},
+		{"name}", nil, ErrTemplateSyntax},
+		{"{}", nil, ErrTemplateSyntax},
+		{"{nmae}", []string{"name"}, ErrTemplateUnknownVar},
+	}
+	for _, tt := range tests {
+		if _, err := ParseTemplate(tt.source, tt.vars...); !errors.Is(err, tt.err) {
+			t.Errorf("ParseTemplate(%q) error = %v, want %v", tt.source, err, tt.err)
+		}
+	}
+
+	tpl := MustTemplate("{a} и {b}", "a", "b")
+	if _, err := tpl.Render(Vars{"a": 1}); !errors.Is(err, ErrTemplateMissingVar) {
+		t.Errorf("Render() error = %v, want %v", err, ErrTemplateMissingVar)
+	}
+	if names := tpl.Placeholders(); len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("Placeholders() = %v, want [a b]", names)
+	}
+}
+
+// Рекурсивный тип для проверки CheckFormat.
+type formatTree struct {
+	Name     string
+	Children []formatTree
+}
+
+func TestCheckFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		args   []any
+		ok     bool
+	}{
+		{"Пользователь %s забанен!", []any{"123"}, true},
+		{"Пользователь %d забанен!", []any{"123"}, false},
+		{"%s и %s", []any{"a"}, false},
+		{"без глаголов", []any{1}, false},
+		{"100%%", nil, true},
+		// проверяются типы, а не результат форматирования
+		{"%s", []any{"%!d"}, true},
+		{"%d", []any{3.5}, false},
+		{"%x и %q", []any{"abc", 'ы'}, true},
+		{"%v %T", []any{nil, nil}, true},
+		{"%s", []any{nil}, false},
+		{"%s", []any{errors.New("ошибка")}, true},
+		{"%d", []any{[]int{1, 2}}, true},
+		{"%s", []any{map[string]int{"a": 1}}, false},
+		{"%*d", []any{5, 1}, true},
+		{"%*d", []any{"5", 1}, false},
+		{"%[2]d %[1]s", []any{"a", 1}, true},
+		{"%[3]d", []any{1}, false},
+		{"%d %", []any{1}, false},
+		{"%s", []any{formatTree{}}, true},
+	}
+	for _, tt := range tests {
+		err := CheckFormat(tt.format, tt.args...)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckFormat(%q, %v) error = %v, want ok = %v", tt.format, tt.args, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrFormatMismatch) {
+			t.Errorf("CheckFormat(%q) error = %v, want %v", tt.format, err, ErrFormatMismatch)
+		}
+	}
+}
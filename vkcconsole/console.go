@@ -0,0 +1,283 @@
+// Пакет vkcconsole позволяет запускать бота в терминале без сообщества VK: строки из стандартного ввода
+// превращаются во входящие сообщения, а ответы бота (с вложениями и клавиатурами) выводятся на экран.
+//
+// Пример использования:
+//
+//	func main() {
+//		commands := vkc.Commands[any]{ /* ... */ }
+//		if err := vkcconsole.New(commands).Run(context.Background()); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+//
+// Служебные команды консоли начинаются с двоеточия, список выводится по ":help".
+//
+// Сообщения обрабатываются по очереди, поэтому обработчики с [vkc.CommandContext.Await] в консоли не дождутся ответа
+// (диалоги [vkc.Dialog] работают как обычно).
+package vkcconsole
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SevereCloud/vksdk/v3/object"
+
+	"txts.su/vkc"
+	"txts.su/vkc/vkctest"
+)
+
+const help = `Служебные команды:
+  :user <id>     писать от имени пользователя
+  :peer <id>     писать в беседу с peer_id
+  :chat <n>      писать в беседу номер n (peer_id = 2000000000 + n)
+  :dm            писать в личные сообщения
+  :press <n>     нажать кнопку n последней клавиатуры
+  :calls         показывать все вызовы API (повторный вызов выключает)
+  :help          эта справка
+  :quit          выход
+Все остальные строки отправляются боту как сообщения.
+`
+
+// Консольный запуск бота.
+type Console[DEPS any] struct {
+	Commands vkc.Commands[DEPS]
+	// Автор и беседа для следующих сообщений. По умолчанию — личные сообщения от пользователя 1.
+	UserID int
+	PeerID int
+	In     io.Reader
+	Out    io.Writer
+
+	api       *vkctest.API
+	mu        sync.Mutex
+	keyboard  *object.MessagesKeyboard
+	showCalls bool
+}
+
+// Создание консоли для объекта команд. Ввод и вывод — стандартные потоки процесса.
+func New[DEPS any](commands vkc.Commands[DEPS]) *Console[DEPS] {
+	return &Console[DEPS]{
+		Commands: commands,
+		UserID:   vkctest.DefaultUserID,
+		PeerID:   vkctest.DefaultUserID,
+		In:       os.Stdin,
+		Out:      os.Stdout,
+	}
+}
+
+func (console *Console[DEPS]) printf(format string, args ...any) {
+	console.mu.Lock()
+	defer console.mu.Unlock()
+	fmt.Fprintf(console.Out, format, args...)
+}
+
+func (console *Console[DEPS]) prompt() {
+	console.printf("[%d → %d] > ", console.UserID, console.PeerID)
+}
+
+// Цикл чтения сообщений до конца ввода, команды ":quit" или отмены контекста.
+//
+// Чтение из In нельзя прервать, поэтому после выхода по ":quit" или отмене контекста читающая горутина
+// завершается, когда из In придет следующая строка или ввод закроется.
+func (console *Console[DEPS]) Run(ctx context.Context) error {
+	console.api = vkctest.NewServer()
+	console.api.OnCall = console.onCall
+	defer console.api.Close()
+
+	lines := make(chan string)
+	scanErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(console.In)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				return
+			}
+		}
+		scanErr <- scanner.Err()
+	}()
+
+	console.printf("Бот запущен в консоли. Справка: :help\n")
+	for {
+		console.prompt()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case line, ok := <-lines:
+			if !ok {
+				console.printf("\n")
+				return <-scanErr
+			}
+			if quit := console.handleLine(ctx, line); quit {
+				return nil
+			}
+		}
+	}
+}
+
+func (console *Console[DEPS]) handleLine(ctx context.Context, line string) (quit bool) {
+	if strings.HasPrefix(line, ":") {
+		return console.handleCommand(ctx, line)
+	}
+	if strings.TrimSpace(line) == "" {
+		return false
+	}
+	console.process(ctx, vkctest.NewMessage(line).From(console.UserID).Peer(console.PeerID))
+	return false
+}
+
+func (console *Console[DEPS]) process(ctx context.Context, msg *vkctest.MessageBuilder) {
+	err := console.Commands.ProcessCommands(ctx, console.api.VK, msg.Event())
+	if err != nil && !errors.Is(err, vkc.ErrNoPrefix) {
+		console.printf("! %v\n", err)
+	}
+}
+
+func (console *Console[DEPS]) handleCommand(ctx context.Context, line string) bool {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(line, ":"), " ")
+	arg = strings.TrimSpace(arg)
+	parseID := func() (int, bool) {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			console.printf("! ожидается число: %q\n", arg)
+			return 0, false
+		}
+		return id, true
+	}
+
+	switch name {
+	case "user":
+		if id, ok := parseID(); ok {
+			if console.PeerID == console.UserID {
+				console.PeerID = id
+			}
+			console.UserID = id
+		}
+	case "peer":
+		if id, ok := parseID(); ok {
+			console.PeerID = id
+		}
+	case "chat":
+		if id, ok := parseID(); ok {
+			console.PeerID = vkc.ChatPeerIDOffset + id
+		}
+	case "dm":
+		console.PeerID = console.UserID
+	case "press":
+		if n, ok := parseID(); ok {
+			console.press(ctx, n)
+		}
+	case "calls":
+		console.mu.Lock()
+		console.showCalls = !console.showCalls
+		console.mu.Unlock()
+	case "help":
+		console.printf("%s", help)
+	case "quit", "q", "exit":
+		return true
+	default:
+		console.printf("! неизвестная команда %q, справка: :help\n", name)
+	}
+	return false
+}
+
+// Нажатие кнопки: текстовая кнопка отправляет сообщение с ее подписью и полезной нагрузкой.
+func (console *Console[DEPS]) press(ctx context.Context, n int) {
+	console.mu.Lock()
+	keyboard := console.keyboard
+	console.mu.Unlock()
+
+	button, ok := keyboardButton(keyboard, n)
+	if !ok {
+		console.printf("! нет кнопки %d\n", n)
+		return
+	}
+	if button.Action.Type != "text" {
+		console.printf("! кнопки типа %q не поддерживаются\n", button.Action.Type)
+		return
+	}
+	console.printf("[%d → %d] * %s\n", console.UserID, console.PeerID, button.Action.Label)
+	msg := vkctest.NewMessage(button.Action.Label).From(console.UserID).Peer(console.PeerID).Payload(button.Action.Payload)
+	console.process(ctx, msg)
+}
+
+func keyboardButton(keyboard *object.MessagesKeyboard, n int) (object.MessagesKeyboardButton, bool) {
+	if keyboard == nil {
+		return object.MessagesKeyboardButton{}, false
+	}
+	i := 0
+	for _, row := range keyboard.Buttons {
+		for _, button := range row {
+			i++
+			if i == n {
+				return button, true
+			}
+		}
+	}
+	return object.MessagesKeyboardButton{}, false
+}
+
+func (console *Console[DEPS]) onCall(call vkctest.Call, _ any) {
+	if call.Method != "messages.send" {
+		console.mu.Lock()
+		show := console.showCalls
+		console.mu.Unlock()
+		switch {
+		case call.Method == "messages.edit":
+			console.printf("  (изменено) %s\n", call.Params.Get("message"))
+		case show:
+			console.printf("  · %s %s\n", call.Method, call.Params.Encode())
+		}
+		return
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "бот → %s:\n", call.Params.Get("peer_ids"))
+	for _, line := range strings.Split(call.Params.Get("message"), "\n") {
+		out.WriteString("  " + line + "\n")
+	}
+	if attachments := call.Params.Get("attachment"); attachments != "" {
+		for _, attachment := range strings.Split(attachments, ",") {
+			out.WriteString("  [вложение] " + attachment + "\n")
+		}
+	}
+	if raw := call.Params.Get("keyboard"); raw != "" {
+		var keyboard object.MessagesKeyboard
+		if err := json.Unmarshal([]byte(raw), &keyboard); err == nil {
+			out.WriteString(renderKeyboard(&keyboard))
+			console.mu.Lock()
+			console.keyboard = &keyboard
+			console.mu.Unlock()
+		}
+	}
+	console.printf("%s", out.String())
+}
+
+func renderKeyboard(keyboard *object.MessagesKeyboard) string {
+	var out strings.Builder
+	i := 0
+	for _, row := range keyboard.Buttons {
+		out.WriteString(" ")
+		for _, button := range row {
+			i++
+			label := button.Action.Label
+			if label == "" {
+				label = button.Action.Type
+			}
+			fmt.Fprintf(&out, " [%d] %s", i, label)
+		}
+		out.WriteString("\n")
+	}
+	return out.String()
+}
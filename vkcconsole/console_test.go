@@ -0,0 +1,73 @@
+package vkcconsole
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/SevereCloud/vksdk/v3/object"
+
+	"txts.su/vkc"
+)
+
+func TestConsole(t *testing.T) {
+	commands := vkc.Commands[any]{
+		Prefix: vkc.PrefixText("!"),
+		Handlers: []*vkc.CommandHandler[any]{
+			{
+				Pattern: vkc.Text("menu"),
+				Executor: func(ctx vkc.CommandContext[any]) error {
+					keyboard := object.NewMessagesKeyboard(true)
+					keyboard.AddRow().AddTextButton("!whoami", map[string]int{"button": 1}, "").AddTextButton("два", nil, "")
+					_, err := ctx.SendMessage(vkc.OutgoingMessage{Text: "Меню", Keyboard: keyboard, Attachments: []string{"photo1_2"}})
+					return err
+				},
+			},
+			{
+				Pattern: vkc.Text("whoami"),
+				Executor: func(ctx vkc.CommandContext[any]) error {
+					return ctx.SendText("%d в %d, payload %s", ctx.Message.FromID, ctx.Message.PeerID, ctx.Message.Payload)
+				},
+			},
+		},
+	}
+
+	var out bytes.Buffer
+	console := New(commands)
+	console.In = strings.NewReader(strings.Join([]string{
+		"!whoami",
+		":chat 1",
+		":user 5",
+		"!whoami",
+		"!menu",
+		":press 1",
+		":press 9",
+		"!unknown",
+		":quit",
+		"!whoami",
+	}, "\n"))
+	console.Out = &out
+
+	if err := console.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	output := out.String()
+	for _, expected := range []string{
+		"1 в 1, payload",
+		"5 в 2000000001, payload",
+		"[вложение] photo1_2",
+		"[1] !whoami [2] два",
+		`5 в 2000000001, payload {"button":1}`,
+		"! нет кнопки 9",
+		"! command not found",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("output does not contain %q:\n%s", expected, output)
+		}
+	}
+	if strings.Count(output, "payload") != 3 {
+		t.Errorf("messages after :quit were processed:\n%s", output)
+	}
+}
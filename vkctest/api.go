@@ -0,0 +1,271 @@
+// Пакет vkctest содержит средства для тестирования обработчиков команд без настоящего VK API:
+// поддельный сервер API, записывающий все вызовы, построитель входящих сообщений и проверки отправленных ответов.
+//
+// Пример теста:
+//
+//	func TestHello(t *testing.T) {
+//		bot := vkctest.New(t, vkc.Commands[any]{
+//			Prefix:   vkc.PrefixText("!"),
+//			Handlers: []*vkc.CommandHandler[any]{&HandleHello},
+//		})
+//		if err := bot.Send("!hello"); err != nil {
+//			t.Fatal(err)
+//		}
+//		bot.ExpectReply("Hello, world!")
+//		bot.ExpectNoReply()
+//	}
+package vkctest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SevereCloud/vksdk/v3/api"
+)
+
+// Вызов метода VK API.
+type Call struct {
+	Method string
+	Params url.Values
+}
+
+// Обработчик вызова метода поддельного API. Возвращаемое значение кодируется в JSON и отправляется в поле response.
+// Чтобы вернуть ошибку VK API, верните [api.Error].
+type MethodHandler func(params url.Values) any
+
+// Поддельный VK API на основе httptest. Записывает все вызовы и отвечает правдоподобными значениями:
+// messages.send возвращает идентификаторы отправленных сообщений, остальные методы — 1.
+// Ответы отдельных методов можно переопределить с помощью [API.Handle].
+//
+// Метод execute поддерживает только код вида "return [API.method({...}), ...];", который формирует пакетная отправка
+// [vkc.VKSender.SendBatch]: каждый вложенный вызов записывается и обрабатывается как отдельный, а ошибки вложенных
+// вызовов возвращаются в execute_errors. Произвольный VKScript не исполняется.
+type API struct {
+	// Клиент VK API, направленный на поддельный сервер. Его следует передавать в [vkc.Commands.ProcessCommands].
+	VK     *api.VK
+	Server *httptest.Server
+	// Вызывается после записи каждого вызова, до отправки ответа. Должен быть задан до первого вызова API.
+	OnCall func(call Call, response any)
+
+	mu        sync.Mutex
+	calls     []Call
+	handlers  map[string]MethodHandler
+	messageID int
+	cmids     map[int]int
+	// количество уже проверенных отправленных сообщений (см. ExpectReply)
+	checked int
+}
+
+// Запуск поддельного API. Сервер останавливается по завершении теста.
+func NewAPI(t testing.TB) *API {
+	t.Helper()
+	fake := NewServer()
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// Запуск поддельного API вне тестов (например, для локального запуска бота). Сервер нужно остановить методом [API.Close].
+func NewServer() *API {
+	fake := &API{
+		handlers: make(map[string]MethodHandler),
+		cmids:    make(map[int]int),
+	}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	fake.VK = api.NewVK("vkctest")
+	fake.VK.MethodURL = fake.Server.URL + "/"
+	return fake
+}
+
+// Остановка сервера.
+func (fake *API) Close() {
+	fake.Server.Close()
+}
+
+// Переопределение ответа метода.
+//
+//	fake.Handle("groups.getById", func(url.Values) any {
+//		return map[string]any{"groups": []map[string]any{{"id": 1, "screen_name": "club1"}}}
+//	})
+func (fake *API) Handle(method string, handler MethodHandler) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.handlers[method] = handler
+}
+
+func (fake *API) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	method := strings.TrimPrefix(r.URL.Path, "/")
+	params := r.PostForm
+	if len(params) == 0 {
+		params = r.Form
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fake.mu.Lock()
+	_, custom := fake.handlers[method]
+	fake.mu.Unlock()
+	if method == "execute" && !custom {
+		fake.call(method, params)
+		response, execErrs := fake.execute(params.Get("code"))
+		if vkErr, ok := response.(api.Error); ok {
+			json.NewEncoder(w).Encode(map[string]any{"error": vkErr})
+			return
+		}
+		body := map[string]any{"response": response}
+		if len(execErrs) > 0 {
+			body["execute_errors"] = execErrs
+		}
+		json.NewEncoder(w).Encode(body)
+		return
+	}
+
+	response := fake.call(method, params)
+	if vkErr, ok := response.(api.Error); ok {
+		json.NewEncoder(w).Encode(map[string]any{"error": vkErr})
+		return
+	}
+	if vkErr, ok := response.(*api.Error); ok {
+		json.NewEncoder(w).Encode(map[string]any{"error": vkErr})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"response": response})
+}
+
+// Запись вызова и получение ответа на него.
+func (fake *API) call(method string, params url.Values) any {
+	fake.mu.Lock()
+	fake.calls = append(fake.calls, Call{Method: method, Params: params})
+	handler, ok := fake.handlers[method]
+	fake.mu.Unlock()
+
+	var response any
+	if ok {
+		response = handler(params)
+	} else {
+		response = fake.defaultResponse(method, params)
+	}
+	if fake.OnCall != nil {
+		fake.OnCall(Call{Method: method, Params: params}, response)
+	}
+	return response
+}
+
+// Выполнение execute с кодом "return [API.method({...}), ...];". На месте неудачных вызовов в ответе стоит false.
+func (fake *API) execute(code string) (any, api.ExecuteErrors) {
+	compileErr := api.Error{Code: api.ErrCompile, Message: "Unable to compile code"}
+	rest, ok := strings.CutPrefix(strings.TrimSpace(code), "return [")
+	if !ok {
+		return compileErr, nil
+	}
+	rest, ok = strings.CutSuffix(rest, "];")
+	if !ok {
+		return compileErr, nil
+	}
+
+	result := []any{}
+	var execErrs api.ExecuteErrors
+	for rest = strings.TrimSpace(rest); rest != ""; {
+		var method string
+		if rest, ok = strings.CutPrefix(rest, "API."); !ok {
+			return compileErr, nil
+		}
+		if method, rest, ok = strings.Cut(rest, "("); !ok {
+			return compileErr, nil
+		}
+
+		var args map[string]string
+		dec := json.NewDecoder(strings.NewReader(rest))
+		if err := dec.Decode(&args); err != nil {
+			return compileErr, nil
+		}
+		rest = strings.TrimSpace(rest[dec.InputOffset():])
+		if rest, ok = strings.CutPrefix(rest, ")"); !ok {
+			return compileErr, nil
+		}
+		rest = strings.TrimSpace(rest)
+		if next, found := strings.CutPrefix(rest, ","); found {
+			rest = strings.TrimSpace(next)
+		} else if rest != "" {
+			return compileErr, nil
+		}
+
+		params := url.Values{}
+		for key, value := range args {
+			params.Set(key, value)
+		}
+		response := fake.call(method, params)
+		if vkErr, ok := response.(*api.Error); ok {
+			response = *vkErr
+		}
+		if vkErr, ok := response.(api.Error); ok {
+			result = append(result, false)
+			execErrs = append(execErrs, api.ExecuteError{Method: method, Code: vkErr.Code, Msg: vkErr.Message})
+			continue
+		}
+		result = append(result, response)
+	}
+	return result, execErrs
+}
+
+func (fake *API) defaultResponse(method string, params url.Values) any {
+	switch method {
+	case "messages.send":
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		peerIDs := params.Get("peer_ids")
+		if peerIDs == "" {
+			fake.messageID++
+			return fake.messageID
+		}
+		var result []map[string]int
+		for _, raw := range strings.Split(peerIDs, ",") {
+			peerID, _ := strconv.Atoi(raw)
+			fake.messageID++
+			fake.cmids[peerID]++
+			result = append(result, map[string]int{
+				"peer_id":                 peerID,
+				"message_id":              fake.messageID,
+				"conversation_message_id": fake.cmids[peerID],
+			})
+		}
+		return result
+	case "messages.pin", "messages.delete":
+		return map[string]any{}
+	}
+	return 1
+}
+
+// Все вызовы API в порядке поступления.
+func (fake *API) Calls() []Call {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return append([]Call(nil), fake.calls...)
+}
+
+// Вызовы указанного метода.
+func (fake *API) CallsTo(method string) []Call {
+	var calls []Call
+	for _, call := range fake.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Очистка записанных вызовов.
+func (fake *API) Reset() {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.calls = nil
+	fake.checked = 0
+}
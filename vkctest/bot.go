@@ -0,0 +1,55 @@
+package vkctest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SevereCloud/vksdk/v3/events"
+
+	"txts.su/vkc"
+)
+
+// Тестовый бот: объект команд, подключенный к поддельному API.
+type Bot[DEPS any] struct {
+	Commands vkc.Commands[DEPS]
+	API      *API
+	// Автор и беседа для сообщений, отправленных через [Bot.Send].
+	UserID int
+	PeerID int
+
+	t testing.TB
+}
+
+// Создание тестового бота с новым поддельным API. По умолчанию сообщения приходят от [DefaultUserID] в личные сообщения.
+func New[DEPS any](t testing.TB, commands vkc.Commands[DEPS]) *Bot[DEPS] {
+	t.Helper()
+	return &Bot[DEPS]{
+		Commands: commands,
+		API:      NewAPI(t),
+		UserID:   DefaultUserID,
+		PeerID:   DefaultUserID,
+		t:        t,
+	}
+}
+
+// Обработка события так же, как при получении его из Long Poll.
+func (bot *Bot[DEPS]) Process(event events.MessageNewObject) error {
+	return bot.Commands.ProcessCommands(context.Background(), bot.API.VK, event)
+}
+
+// Обработка сообщения с текстом text от [Bot.UserID] в [Bot.PeerID].
+func (bot *Bot[DEPS]) Send(text string) error {
+	return bot.Process(NewMessage(text).From(bot.UserID).Peer(bot.PeerID).Event())
+}
+
+// См. [API.ExpectReply].
+func (bot *Bot[DEPS]) ExpectReply(text string) SentMessage {
+	bot.t.Helper()
+	return bot.API.ExpectReply(bot.t, text)
+}
+
+// См. [API.ExpectNoReply].
+func (bot *Bot[DEPS]) ExpectNoReply() {
+	bot.t.Helper()
+	bot.API.ExpectNoReply(bot.t)
+}
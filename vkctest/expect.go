@@ -0,0 +1,51 @@
+package vkctest
+
+import (
+	"testing"
+)
+
+// Следующее непроверенное отправленное сообщение. Каждое сообщение проверяется не более одного раза.
+func (fake *API) nextSent() (SentMessage, bool) {
+	sent := fake.Sent()
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.checked >= len(sent) {
+		return SentMessage{}, false
+	}
+	msg := sent[fake.checked]
+	fake.checked++
+	return msg, true
+}
+
+// Проверка, что следующее отправленное ботом сообщение имеет текст text. Возвращает это сообщение для дальнейших проверок.
+func (fake *API) ExpectReply(t testing.TB, text string) SentMessage {
+	t.Helper()
+	msg, ok := fake.nextSent()
+	if !ok {
+		t.Errorf("expected reply %q, got no reply", text)
+		return SentMessage{}
+	}
+	if msg.Text != text {
+		t.Errorf("reply = %q, want %q", msg.Text, text)
+	}
+	return msg
+}
+
+// Проверка, что бот больше не отправлял сообщений.
+func (fake *API) ExpectNoReply(t testing.TB) {
+	t.Helper()
+	if msg, ok := fake.nextSent(); ok {
+		t.Errorf("expected no reply, got %q", msg.Text)
+	}
+}
+
+// Проверка, что метод API был вызван хотя бы раз. Возвращает последний вызов.
+func (fake *API) ExpectCall(t testing.TB, method string) Call {
+	t.Helper()
+	calls := fake.CallsTo(method)
+	if len(calls) == 0 {
+		t.Errorf("expected call to %s, got none", method)
+		return Call{}
+	}
+	return calls[len(calls)-1]
+}
@@ -0,0 +1,138 @@
+package vkctest
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/SevereCloud/vksdk/v3/events"
+	"github.com/SevereCloud/vksdk/v3/object"
+
+	"txts.su/vkc"
+)
+
+// Пользователь, от имени которого по умолчанию приходят сообщения.
+const DefaultUserID = 1
+
+var lastMessageID atomic.Int64
+
+// Построитель входящего сообщения (события message_new). По умолчанию сообщение приходит от [DefaultUserID] в личные сообщения.
+//
+//	event := vkctest.NewMessage("!ban 123").From(1).Chat(1).Event()
+type MessageBuilder struct {
+	event events.MessageNewObject
+}
+
+// Создание сообщения с текстом. Идентификаторы сообщения назначаются по возрастанию.
+func NewMessage(text string) *MessageBuilder {
+	id := int(lastMessageID.Add(1))
+	return &MessageBuilder{event: events.MessageNewObject{
+		Message: object.MessagesMessage{
+			ID:                    id,
+			ConversationMessageID: id,
+			PeerID:                DefaultUserID,
+			FromID:                DefaultUserID,
+			Text:                  text,
+		},
+		ClientInfo: object.ClientInfo{Keyboard: true, InlineKeyboard: true},
+	}}
+}
+
+// Автор сообщения. Для личных сообщений беседа тоже меняется на автора.
+func (b *MessageBuilder) From(userID int) *MessageBuilder {
+	if b.event.Message.PeerID == b.event.Message.FromID {
+		b.event.Message.PeerID = userID
+	}
+	b.event.Message.FromID = userID
+	return b
+}
+
+// Беседа по peer_id.
+func (b *MessageBuilder) Peer(peerID int) *MessageBuilder {
+	b.event.Message.PeerID = peerID
+	return b
+}
+
+// Беседа по номеру чата (peer_id = 2000000000 + chatID).
+func (b *MessageBuilder) Chat(chatID int) *MessageBuilder {
+	return b.Peer(vkc.ChatPeerIDOffset + chatID)
+}
+
+// Идентификатор сообщения в беседе.
+func (b *MessageBuilder) ConversationMessageID(cmid int) *MessageBuilder {
+	b.event.Message.ConversationMessageID = cmid
+	return b
+}
+
+// Ответ на сообщение.
+func (b *MessageBuilder) ReplyTo(msg object.MessagesMessage) *MessageBuilder {
+	b.event.Message.ReplyMessage = &msg
+	return b
+}
+
+// Пересланные сообщения.
+func (b *MessageBuilder) Forward(msgs ...object.MessagesMessage) *MessageBuilder {
+	b.event.Message.FwdMessages = append(b.event.Message.FwdMessages, msgs...)
+	return b
+}
+
+// Полезная нагрузка кнопки клавиатуры (как при нажатии кнопки с текстом сообщения).
+func (b *MessageBuilder) Payload(payload string) *MessageBuilder {
+	b.event.Message.Payload = payload
+	return b
+}
+
+// Готовое событие для [vkc.Commands.ProcessCommands].
+func (b *MessageBuilder) Event() events.MessageNewObject {
+	return b.event
+}
+
+// Сообщение из события.
+func (b *MessageBuilder) Message() object.MessagesMessage {
+	return b.event.Message
+}
+
+// Сообщение, отправленное ботом через messages.send.
+type SentMessage struct {
+	PeerIDs     []int
+	Text        string
+	ReplyTo     int
+	Attachments []string
+	// Клавиатура в JSON, как она была передана в API.
+	Keyboard   string
+	FormatData string
+	RandomID   int
+}
+
+func parseSent(call Call) SentMessage {
+	p := call.Params
+	sent := SentMessage{
+		Text:       p.Get("message"),
+		Keyboard:   p.Get("keyboard"),
+		FormatData: p.Get("format_data"),
+	}
+	sent.ReplyTo, _ = strconv.Atoi(p.Get("reply_to"))
+	sent.RandomID, _ = strconv.Atoi(p.Get("random_id"))
+	peers := p.Get("peer_ids")
+	if peers == "" {
+		peers = p.Get("peer_id")
+	}
+	for _, raw := range strings.Split(peers, ",") {
+		if peerID, err := strconv.Atoi(raw); err == nil {
+			sent.PeerIDs = append(sent.PeerIDs, peerID)
+		}
+	}
+	if attachments := p.Get("attachment"); attachments != "" {
+		sent.Attachments = strings.Split(attachments, ",")
+	}
+	return sent
+}
+
+// Все сообщения, отправленные через messages.send.
+func (fake *API) Sent() []SentMessage {
+	var sent []SentMessage
+	for _, call := range fake.CallsTo("messages.send") {
+		sent = append(sent, parseSent(call))
+	}
+	return sent
+}
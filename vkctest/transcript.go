@@ -0,0 +1,201 @@
+package vkctest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"txts.su/vkc"
+)
+
+// Переменная окружения, включающая перезапись файлов переписок: VKCTEST_UPDATE=1 go test ./...
+const UpdateEnv = "VKCTEST_UPDATE"
+
+// Перезаписывать файлы переписок фактическими ответами бота вместо сравнения. Включается также переменной окружения [UpdateEnv].
+//
+// Пакет не регистрирует флагов; если в тестах нужен флаг, его можно объявить самостоятельно:
+//
+//	var update = flag.Bool("update", false, "update golden files")
+//
+//	func TestMain(m *testing.M) {
+//		flag.Parse()
+//		vkctest.Update = *update
+//		os.Exit(m.Run())
+//	}
+var Update bool
+
+func updating() bool {
+	return Update || os.Getenv(UpdateEnv) != ""
+}
+
+// Прогон переписки из файла (golden-тест). Формат файла:
+//
+//	# комментарий
+//	@user 5                   — следующие сообщения отправляет пользователь 5
+//	@chat 1                   — ...в беседу с номером 1 (или @peer 2000000001)
+//	> !ban 123                — входящее сообщение
+//	< Пользователь 123 забанен! — ответ бота
+//	| вторая строка ответа    — продолжение многострочного текста (и для входящих, и для ответов)
+//	  attachment: photo1_2    — вложение ответа
+//	  keyboard: {...}         — клавиатура ответа в JSON
+//	! command not found       — ошибка обработки (кроме ErrNoPrefix)
+//
+// Входящие сообщения по очереди передаются в [vkc.Commands.ProcessCommands]; ответы, ошибки и вложения сравниваются с файлом.
+// Если включен [Update], файл перезаписывается фактическим результатом.
+func (bot *Bot[DEPS]) RunTranscript(path string) {
+	bot.t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		bot.t.Fatalf("read transcript: %v", err)
+	}
+	expected := string(data)
+	actual := bot.runTranscript(expected)
+	if actual == expected {
+		return
+	}
+	if updating() {
+		if err := os.WriteFile(path, []byte(actual), 0o644); err != nil {
+			bot.t.Fatalf("update transcript: %v", err)
+		}
+		return
+	}
+	bot.t.Errorf("transcript %s mismatch (run with %s=1 to accept):\n%s", path, UpdateEnv, transcriptDiff(expected, actual))
+}
+
+// Прогон всех переписок, подходящих под шаблон пути (например, "testdata/*.txt"), в отдельных подтестах.
+// Для каждой переписки создается новый бот функцией newBot.
+func RunTranscripts[DEPS any](t *testing.T, pattern string, newBot func(t *testing.T) *Bot[DEPS]) {
+	t.Helper()
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatalf("transcripts: %v", err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("transcripts: no files match %q", pattern)
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			newBot(t).RunTranscript(path)
+		})
+	}
+}
+
+func (bot *Bot[DEPS]) runTranscript(source string) string {
+	var out strings.Builder
+	lines := strings.Split(strings.TrimSuffix(source, "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "> ") || line == ">":
+			text := strings.TrimPrefix(strings.TrimPrefix(line, ">"), " ")
+			out.WriteString(line + "\n")
+			for i+1 < len(lines) && isContinuation(lines[i+1]) {
+				i++
+				out.WriteString(lines[i] + "\n")
+				text += "\n" + continuationText(lines[i])
+			}
+			// ожидаемые результаты из файла пропускаются, вместо них пишутся фактические
+			for i+1 < len(lines) && isResultLine(lines[i+1]) {
+				i++
+			}
+			bot.writeResults(&out, bot.Send(text))
+		case strings.HasPrefix(line, "@"):
+			out.WriteString(line + "\n")
+			bot.applyDirective(line)
+		case isResultLine(line):
+			// результат без входящего сообщения: отбрасывается
+		default:
+			out.WriteString(line + "\n")
+		}
+	}
+	return out.String()
+}
+
+func isContinuation(line string) bool {
+	return strings.HasPrefix(line, "| ") || line == "|"
+}
+
+func continuationText(line string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(line, "|"), " ")
+}
+
+func isResultLine(line string) bool {
+	return strings.HasPrefix(line, "<") || strings.HasPrefix(line, "!") || isContinuation(line) || strings.HasPrefix(line, "  ")
+}
+
+func (bot *Bot[DEPS]) applyDirective(line string) {
+	bot.t.Helper()
+	name, value, _ := strings.Cut(strings.TrimPrefix(line, "@"), " ")
+	id, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		bot.t.Fatalf("transcript directive %q: %v", line, err)
+	}
+	switch name {
+	case "user":
+		if bot.PeerID == bot.UserID {
+			bot.PeerID = id
+		}
+		bot.UserID = id
+	case "peer":
+		bot.PeerID = id
+	case "chat":
+		bot.PeerID = vkc.ChatPeerIDOffset + id
+	default:
+		bot.t.Fatalf("transcript directive %q: unknown directive", line)
+	}
+}
+
+func (bot *Bot[DEPS]) writeResults(out *strings.Builder, err error) {
+	if err != nil && !errors.Is(err, vkc.ErrNoPrefix) {
+		out.WriteString("! " + err.Error() + "\n")
+	}
+	for {
+		msg, ok := bot.API.nextSent()
+		if !ok {
+			return
+		}
+		for j, line := range strings.Split(msg.Text, "\n") {
+			if j == 0 {
+				out.WriteString("< " + line + "\n")
+			} else {
+				out.WriteString("| " + line + "\n")
+			}
+		}
+		for _, attachment := range msg.Attachments {
+			out.WriteString("  attachment: " + attachment + "\n")
+		}
+		if msg.Keyboard != "" {
+			out.WriteString("  keyboard: " + msg.Keyboard + "\n")
+		}
+	}
+}
+
+// Построчное сравнение для сообщения об ошибке: отличающиеся строки помечаются "-" (ожидалось) и "+" (получено).
+func transcriptDiff(expected, actual string) string {
+	exp := strings.Split(expected, "\n")
+	act := strings.Split(actual, "\n")
+	var out strings.Builder
+	for i := 0; i < max(len(exp), len(act)); i++ {
+		var e, a string
+		if i < len(exp) {
+			e = exp[i]
+		}
+		if i < len(act) {
+			a = act[i]
+		}
+		if e == a {
+			out.WriteString("  " + e + "\n")
+			continue
+		}
+		if i < len(exp) {
+			out.WriteString("- " + e + "\n")
+		}
+		if i < len(act) {
+			out.WriteString("+ " + a + "\n")
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,144 @@
+package vkctest
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/SevereCloud/vksdk/v3/api"
+	"github.com/SevereCloud/vksdk/v3/object"
+
+	"txts.su/vkc"
+)
+
+func newTestBot(t *testing.T) *Bot[any] {
+	return New(t, vkc.Commands[any]{
+		Prefix: vkc.PrefixText("!"),
+		Handlers: []*vkc.CommandHandler[any]{
+			{
+				Pattern: vkc.Text("hello"),
+				Executor: func(ctx vkc.CommandContext[any]) error {
+					return ctx.SendText("Hello, world!")
+				},
+			},
+			{
+				Pattern: vkc.Text("ban"),
+				Executor: func(ctx vkc.CommandContext[any]) error {
+					return ctx.Reply("Пользователь %s забанен!", strings.Join(ctx.Arguments, " "))
+				},
+			},
+		},
+	})
+}
+
+func TestBot(t *testing.T) {
+	bot := newTestBot(t)
+
+	if err := bot.Send("!hello"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	bot.ExpectReply("Hello, world!")
+	bot.ExpectNoReply()
+
+	bot.PeerID = vkc.ChatPeerIDOffset + 1
+	event := NewMessage("!ban 123").Chat(1).Event()
+	if err := bot.Process(event); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	reply := bot.ExpectReply("Пользователь 123 забанен!")
+	if reply.ReplyTo != event.Message.ID || len(reply.PeerIDs) != 1 || reply.PeerIDs[0] != bot.PeerID {
+		t.Errorf("reply = %+v, want reply to %d in %d", reply, event.Message.ID, bot.PeerID)
+	}
+
+	if err := bot.Send("!unknown"); !errors.Is(err, vkc.ErrCommandNotFound) {
+		t.Errorf("Send(unknown) error = %v, want %v", err, vkc.ErrCommandNotFound)
+	}
+	bot.ExpectNoReply()
+}
+
+func TestAPIHandle(t *testing.T) {
+	fake := NewAPI(t)
+	fake.Handle("messages.send", func(url.Values) any {
+		return api.Error{Code: api.ErrFlood, Message: "Flood control"}
+	})
+
+	_, err := vkc.VKSender{VK: fake.VK}.Send(t.Context(), vkc.OutgoingMessage{PeerID: 1, Text: "привет"})
+	if !errors.Is(err, api.ErrFlood) {
+		t.Errorf("Send() error = %v, want %v", err, api.ErrFlood)
+	}
+	call := fake.ExpectCall(t, "messages.send")
+	if call.Params.Get("message") != "привет" {
+		t.Errorf("message param = %q, want %q", call.Params.Get("message"), "привет")
+	}
+}
+
+func TestAPIExecuteBatch(t *testing.T) {
+	fake := NewAPI(t)
+	msgs := []vkc.OutgoingMessage{{PeerID: 1, Text: "один"}, {PeerID: 2, Text: "два"}, {PeerID: 3, Text: "три"}}
+
+	sent, errs := vkc.VKSender{VK: fake.VK}.SendBatch(t.Context(), msgs)
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("SendBatch() error %d = %v", i, err)
+		}
+	}
+	if sent[2].PeerID != 3 || sent[2].ConversationMessageID != 1 {
+		t.Errorf("SendBatch() sent[2] = %+v, want peer 3 cmid 1", sent[2])
+	}
+	calls := fake.CallsTo("messages.send")
+	if len(calls) != 3 || calls[1].Params.Get("message") != "два" || len(fake.CallsTo("execute")) != 1 {
+		t.Errorf("calls = %v, want one execute with 3 messages.send", fake.Calls())
+	}
+
+	// ошибки вложенных вызовов возвращаются через execute_errors
+	fake.Handle("messages.send", func(params url.Values) any {
+		if params.Get("peer_ids") == "2" {
+			return api.Error{Code: api.ErrTooMany, Message: "Too many requests per second"}
+		}
+		return []map[string]int{{"peer_id": 1, "message_id": 10}}
+	})
+	_, errs = vkc.VKSender{VK: fake.VK}.SendBatch(t.Context(), msgs)
+	if errs[0] != nil || !errors.Is(errs[1], api.ErrTooMany) || errs[2] != nil {
+		t.Errorf("SendBatch() errors = %v, want only second %v", errs, api.ErrTooMany)
+	}
+}
+
+func TestMessageBuilder(t *testing.T) {
+	msg := NewMessage("текст").From(5).Message()
+	if msg.FromID != 5 || msg.PeerID != 5 {
+		t.Errorf("From(5) in direct messages: from = %d, peer = %d, want 5, 5", msg.FromID, msg.PeerID)
+	}
+	msg = NewMessage("текст").Chat(3).From(5).Message()
+	if msg.FromID != 5 || msg.PeerID != vkc.ChatPeerIDOffset+3 {
+		t.Errorf("Chat(3).From(5): from = %d, peer = %d", msg.FromID, msg.PeerID)
+	}
+}
+
+func TestRunTranscripts(t *testing.T) {
+	RunTranscripts(t, "testdata/*.txt", func(t *testing.T) *Bot[any] {
+		bot := newTestBot(t)
+		bot.Commands.Handlers = append(bot.Commands.Handlers, &vkc.CommandHandler[any]{
+			Pattern: vkc.Text("menu"),
+			Executor: func(ctx vkc.CommandContext[any]) error {
+				keyboard := object.NewMessagesKeyboard(true)
+				keyboard.AddRow().AddTextButton("один", nil, "")
+				_, err := ctx.SendMessage(vkc.OutgoingMessage{
+					Text:        "Выберите:\nодин или два",
+					Attachments: []string{"photo1_2"},
+					Keyboard:    keyboard,
+				})
+				return err
+			},
+		})
+		return bot
+	})
+}
+
+func TestTranscriptMismatch(t *testing.T) {
+	bot := newTestBot(t)
+	actual := bot.runTranscript("> !hello\n< Привет!\n")
+	if expected := "> !hello\n< Hello, world!\n"; actual != expected {
+		t.Errorf("runTranscript() = %q, want %q", actual, expected)
+	}
+}